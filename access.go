@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+func getSpecimen(ctx contractapi.TransactionContextInterface, guid string) (*Specimen, error) {
+	specimenBytes, err := getState(ctx, specimenObjectType, guid)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if specimenBytes == nil {
+		return nil, fmt.Errorf("%s does not exist", guid)
+	}
+
+	specimen := new(Specimen)
+	_ = json.Unmarshal(specimenBytes, specimen)
+
+	return specimen, nil
+}
+
+func getCollection(ctx contractapi.TransactionContextInterface, name string) (*Collection, error) {
+	collectionBytes, err := getState(ctx, collectionObjectType, name)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if collectionBytes == nil {
+		return nil, fmt.Errorf("Collection %s does not exist", name)
+	}
+
+	collect := new(Collection)
+	_ = json.Unmarshal(collectionBytes, collect)
+
+	return collect, nil
+}
+
+// roleIn returns the user's role in a collection, treating non-members as public
+func roleIn(user *User, collection string) string {
+	role, ok := user.Membership[collection]
+
+	if !ok {
+		role = "P"
+	}
+
+	return role
+}
+
+func collectionQueryAccess(ctx contractapi.TransactionContextInterface, collection string, username string) error {
+	_, err := collectionQueryPermissions(ctx, collection, username)
+
+	return err
+}
+
+// collectionQueryPermissions checks that the user may query a collection and returns the permissions its specimens are redacted with
+func collectionQueryPermissions(ctx contractapi.TransactionContextInterface, collection string, username string) (*queryPermissions, error) {
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	collect, err := getCollection(ctx, collection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	role := roleIn(user, collection)
+
+	if !strings.Contains(collect.Query, role) {
+		return nil, fmt.Errorf("%s has role %s but role %s is required to query specimens", user.Username, role, collect.Query)
+	}
+
+	permissions := newQueryPermissions(user)
+	permissions.collections[collection] = collect
+
+	return permissions, nil
+}
+
+const isoDateLayout = "2006-01-02"
+
+// indexValue is stored under index keys, since an empty value would delete the key
+var indexValue = []byte{0x00}
+
+func txTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Failed to get transaction timestamp. %s", err.Error())
+	}
+
+	return time.Unix(timestamp.Seconds, int64(timestamp.Nanos)).UTC(), nil
+}
+
+func txDate(ctx contractapi.TransactionContextInterface) (string, error) {
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	return now.Format(isoDateLayout), nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// SpecimenSnapshot is the version of a specimen current at the time of a collection snapshot
+type SpecimenSnapshot struct {
+	Guid      string    `json:"guid"`
+	TxID      string    `json:"txId"`
+	Timestamp string    `json:"timestamp"`
+	Hidden    bool      `json:"hidden"`
+	Record    *Specimen `json:"specimen"`
+}
+
+type SpecimenSnapshotPage struct {
+	Records      []SpecimenSnapshot `json:"records"`
+	FetchedCount int32              `json:"fetchedCount"`
+	Bookmark     string             `json:"bookmark"`
+}
+
+// asOfTime parses a point in time given as an RFC 3339 timestamp, or as an ISO 8601 date which is taken as the end of that day in UTC
+func asOfTime(asOf string) (time.Time, error) {
+	timestamp, err := time.Parse(time.RFC3339Nano, asOf)
+
+	if err == nil {
+		return timestamp.UTC(), nil
+	}
+
+	date, err := time.Parse(isoDateLayout, asOf)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s is not an RFC 3339 timestamp or an ISO 8601 date (YYYY-MM-DD)", asOf)
+	}
+
+	return date.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
+
+// versionAsOf returns the latest version of a history written at or before a point in time, or nil if the specimen did not exist yet
+func versionAsOf(entries []HistoryEntry, at time.Time) *HistoryEntry {
+	var current *HistoryEntry
+
+	for i := range entries {
+		timestamp, _ := time.Parse(time.RFC3339Nano, entries[i].Timestamp)
+
+		if timestamp.After(at) {
+			break
+		}
+
+		current = &entries[i]
+	}
+
+	return current
+}
+
+// QuerySpecimenAsOf returns the version of a specimen written by a transaction, or current at an RFC 3339 timestamp or the end of an ISO 8601 date
+func (s *SmartContract) QuerySpecimenAsOf(ctx contractapi.TransactionContextInterface, guid string, username string, asOf string) (*HistoryEntry, error) {
+	entries, err := specimenHistory(ctx, guid, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		if entries[i].TxID == asOf {
+			return &entries[i], nil
+		}
+	}
+
+	at, err := asOfTime(asOf)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a transaction in the history of specimen %s. %s", asOf, guid, err.Error())
+	}
+
+	entry := versionAsOf(entries, at)
+
+	if entry == nil {
+		return nil, fmt.Errorf("%s did not exist as of %s", guid, asOf)
+	}
+
+	return entry, nil
+}
+
+// QueryCollectionAsOf returns one page of the versions of a collection's specimens current at an RFC 3339 timestamp or the end of an ISO 8601 date.
+// Specimens are found through the collection's current specimens. A specimen's collection cannot be changed, so every version found belongs to the collection.
+func (s *SmartContract) QueryCollectionAsOf(ctx contractapi.TransactionContextInterface, collection string, username string, asOf string, pageSize int32, bookmark string) (*SpecimenSnapshotPage, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("Page size must be positive")
+	}
+
+	permissions, err := collectionQueryPermissions(ctx, collection, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	at, err := asOfTime(asOf)
+
+	if err != nil {
+		return nil, err
+	}
+
+	guids, metadata, err := pagedIndexedIDs(ctx, collectionTaxonIndex, []string{collection}, pageSize, bookmark)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := []SpecimenSnapshot{}
+
+	for _, guid := range guids {
+		specimen, err := getSpecimen(ctx, guid)
+
+		if err != nil {
+			return nil, err
+		}
+
+		entries, err := historyEntries(ctx, guid, specimen, permissions)
+
+		if err != nil {
+			return nil, err
+		}
+
+		entry := versionAsOf(entries, at)
+
+		//A specimen which did not exist yet or had been deleted is not part of the snapshot
+		if entry == nil || entry.IsDelete {
+			continue
+		}
+
+		results = append(results, SpecimenSnapshot{guid, entry.TxID, entry.Timestamp, entry.Hidden, entry.Record})
+	}
+
+	return &SpecimenSnapshotPage{results, metadata.FetchedRecordsCount, metadata.Bookmark}, nil
+}
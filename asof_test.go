@@ -0,0 +1,111 @@
+package main
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestQuerySpecimenAsOf(t *testing.T) {
+	h := newContractHarness(t)
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"manager","preparation":"skin"}`)
+	skin := h.tx
+	between := h.now().Add(-time.Second)
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"manager","preparation":"skeleton"}`)
+
+	entry := HistoryEntry{}
+	h.okInto(&entry, "QuerySpecimenAsOf", "0", "public", between.Format(time.RFC3339))
+
+	if entry.TxID != skin || entry.Record.Preparation != "skin" {
+		t.Errorf("Version as of %s is %+v", between, entry)
+	}
+
+	h.okInto(&entry, "QuerySpecimenAsOf", "0", "public", skin)
+
+	if entry.TxID != skin {
+		t.Errorf("Version written by %s is %+v", skin, entry)
+	}
+
+	h.okInto(&entry, "QuerySpecimenAsOf", "0", "public", testEpoch.Format(isoDateLayout))
+
+	if entry.Record.Preparation != "skeleton" {
+		t.Errorf("Version as of the end of the day is %+v", entry)
+	}
+
+	h.fail("QuerySpecimenAsOf", "0", "public", "2000-01-01")
+	h.fail("QuerySpecimenAsOf", "0", "public", "yesterday")
+	h.fail("QuerySpecimenAsOf", "0", "nobody", skin)
+}
+
+func TestVersionsWithinOneSecondAreToldApart(t *testing.T) {
+	h := newContractHarness(t)
+	h.step = time.Millisecond
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"manager","preparation":"skin"}`)
+	skin := h.tx
+	between := h.now().Add(-time.Microsecond)
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"manager","preparation":"skeleton"}`)
+	skeleton := h.clock.Format(time.RFC3339Nano)
+
+	entries := []HistoryEntry{}
+	h.okInto(&entries, "GetHistory", "0", "public")
+
+	if last := entries[len(entries)-1]; last.Timestamp != skeleton {
+		t.Errorf("Version was written at %s rather than %s", last.Timestamp, skeleton)
+	}
+
+	entry := HistoryEntry{}
+	h.okInto(&entry, "QuerySpecimenAsOf", "0", "public", between.Format(time.RFC3339Nano))
+
+	if entry.TxID != skin {
+		t.Errorf("Version as of %s is %+v", between.Format(time.RFC3339Nano), entry)
+	}
+}
+
+func TestQueryCollectionAsOfInPages(t *testing.T) {
+	h := newContractHarness(t)
+	createSpecimens(h, "Pomacanthus imperator", "a", "b")
+	between := h.now().Add(-time.Second)
+	createSpecimens(h, "Pomacanthus imperator", "c")
+	h.ok("PatchSpecimen", `{"guid":"a","updater":"manager","preparation":"skin"}`)
+
+	h.fail("QueryCollectionAsOf", "KU Ornithology", "public", between.Format(time.RFC3339), "0", "")
+	h.fail("QueryCollectionAsOf", "KU Ornithology", "nobody", between.Format(time.RFC3339), "2", "")
+
+	snapshots := []SpecimenSnapshot{}
+	bookmark := ""
+	pages := 0
+
+	for {
+		page := SpecimenSnapshotPage{}
+		h.okInto(&page, "QueryCollectionAsOf", "KU Ornithology", "public", between.Format(time.RFC3339), "2", bookmark)
+		snapshots = append(snapshots, page.Records...)
+		bookmark = page.Bookmark
+		pages++
+
+		if page.FetchedCount < 2 {
+			break
+		}
+	}
+
+	guids := []string{}
+	for _, snapshot := range snapshots {
+		guids = append(guids, snapshot.Guid)
+
+		if snapshot.Record.Preparation == "skin" {
+			t.Errorf("Snapshot holds the later version of %s", snapshot.Guid)
+		}
+	}
+
+	//Specimen c was created after the snapshot
+	sort.Strings(guids)
+	if !equalGuids(guids, "0", "a", "b") || pages != 3 {
+		t.Errorf("Snapshot holds %v in %d pages", guids, pages)
+	}
+
+	page := SpecimenSnapshotPage{}
+	h.okInto(&page, "QueryCollectionAsOf", "KU Ornithology", "public", "2000-01-01", "10", "")
+
+	if len(page.Records) != 0 {
+		t.Errorf("Snapshot before any specimen existed holds %+v", page.Records)
+	}
+}
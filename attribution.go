@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+type Contribution struct {
+	Actor       string `json:"actor"`
+	Action      string `json:"action"`
+	ObjectType  string `json:"objectType"`
+	Target      string `json:"target"`
+	Guid        string `json:"guid"`
+	Description string `json:"description"`
+	TxID        string `json:"txId"`
+	Timestamp   string `json:"timestamp"`
+}
+
+type Contributor struct {
+	Username      string         `json:"username"`
+	Contributions int            `json:"contributions"`
+	Actions       map[string]int `json:"actions"`
+}
+
+// attribute records a contribution in the actor's append-only ledger and keeps the actor's latest attribution string up to date.
+// guid names the specimen the contribution should be credited to, and is blank for contributions to no particular specimen.
+func attribute(ctx contractapi.TransactionContextInterface, actor string, action string, objectType string, target string, guid string, description string) error {
+	err := putState(ctx, attributionObjectType, actor, []byte(description))
+
+	if err != nil {
+		return err
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	txID := ctx.GetStub().GetTxID()
+	contribution := Contribution{actor, action, objectType, target, guid, description, txID, now.Format(time.RFC3339)}
+
+	key, err := stateKey(ctx, contributionObjectType, actor, txID, action, objectType, target)
+
+	if err != nil {
+		return err
+	}
+
+	contributionBytes, _ := json.Marshal(contribution)
+	err = ctx.GetStub().PutState(key, contributionBytes)
+
+	if err != nil {
+		return err
+	}
+
+	if guid == "" {
+		return nil
+	}
+
+	return putIndex(ctx, true, specimenContributorIndex, guid, actor, action, txID)
+}
+
+func contributions(ctx contractapi.TransactionContextInterface, contributor string) ([]Contribution, error) {
+	recordIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(contributionObjectType, []string{contributor})
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get record iterator. %s", err.Error())
+	}
+
+	defer recordIterator.Close()
+
+	results := []Contribution{}
+
+	for recordIterator.HasNext() {
+		record, err := recordIterator.Next()
+
+		if err != nil {
+			return nil, fmt.Errorf("Error. %s", err.Error())
+		}
+
+		contribution := Contribution{}
+		_ = json.Unmarshal(record.Value, &contribution)
+
+		results = append(results, contribution)
+	}
+
+	//Keys are ordered by transaction id, so put the contributions back in the order they were made
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Timestamp < results[j].Timestamp
+	})
+
+	return results, nil
+}
+
+// visibleContributions returns the contributions of a contributor to the specimens and collections the user may query, and those made to no collection, such as to users and taxa
+func visibleContributions(ctx contractapi.TransactionContextInterface, contributor string, username string) ([]Contribution, error) {
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	allContributions, err := contributions(ctx, contributor)
+
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := newQueryPermissions(user)
+	specimenCollections := make(map[string]string)
+	results := []Contribution{}
+
+	for _, contribution := range allContributions {
+		collection := ""
+
+		if contribution.Guid != "" {
+			specimenCollection, ok := specimenCollections[contribution.Guid]
+
+			if !ok {
+				specimen, err := getSpecimen(ctx, contribution.Guid)
+
+				if err != nil {
+					return nil, err
+				}
+
+				specimenCollection = specimen.Collection
+				specimenCollections[contribution.Guid] = specimenCollection
+			}
+
+			collection = specimenCollection
+		} else if contribution.ObjectType == collectionObjectType {
+			collection = contribution.Target
+		}
+
+		if collection != "" {
+			allowed, err := permissions.canQueryCollection(ctx, collection)
+
+			if err != nil {
+				return nil, err
+			}
+			if !allowed {
+				continue
+			}
+		}
+
+		results = append(results, contribution)
+	}
+
+	return results, nil
+}
+
+func (s *SmartContract) QueryContributions(ctx contractapi.TransactionContextInterface, contributor string, username string) ([]Contribution, error) {
+	return visibleContributions(ctx, contributor, username)
+}
+
+func (s *SmartContract) CountContributions(ctx contractapi.TransactionContextInterface, contributor string, username string) (map[string]int, error) {
+	results, err := visibleContributions(ctx, contributor, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+
+	for _, contribution := range results {
+		counts[contribution.Action] += 1
+	}
+
+	return counts, nil
+}
+
+// QuerySpecimenContributors lists everyone who has contributed to a specimen, with how often they contributed and in what way
+func (s *SmartContract) QuerySpecimenContributors(ctx contractapi.TransactionContextInterface, guid string, username string) ([]Contributor, error) {
+	specimen, err := getSpecimen(ctx, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = collectionQueryAccess(ctx, specimen.Collection, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	recordIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(specimenContributorIndex, []string{guid})
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get record iterator. %s", err.Error())
+	}
+
+	defer recordIterator.Close()
+
+	results := []Contributor{}
+	positions := map[string]int{}
+
+	for recordIterator.HasNext() {
+		record, err := recordIterator.Next()
+
+		if err != nil {
+			return nil, fmt.Errorf("Error. %s", err.Error())
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(record.Key)
+
+		if err != nil || len(attributes) < 3 {
+			return nil, fmt.Errorf("Failed to split key %s", record.Key)
+		}
+
+		actor, action := attributes[1], attributes[2]
+		position, ok := positions[actor]
+
+		if !ok {
+			position = len(results)
+			positions[actor] = position
+			results = append(results, Contributor{actor, 0, map[string]int{}})
+		}
+
+		results[position].Contributions += 1
+		results[position].Actions[action] += 1
+	}
+
+	return results, nil
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestContributionsLeaveOutCollectionsUserMayNotQuery(t *testing.T) {
+	h := newContractHarness(t)
+	registerHerpetology(h)
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"curator","preparation":"skin"}`)
+
+	contributions := []Contribution{}
+	h.okInto(&contributions, "QueryContributions", "curator", "curator")
+
+	if len(contributions) != 3 || contributions[0].Action != "RegisterCollection" || contributions[2].Target != "0" {
+		t.Errorf("Curator's own contributions are %+v", contributions)
+	}
+
+	h.okInto(&contributions, "QueryContributions", "curator", "public")
+
+	if len(contributions) != 1 || contributions[0].Guid != "0" {
+		t.Errorf("Public user queried contributions %+v", contributions)
+	}
+
+	counts := map[string]int{}
+	h.okInto(&counts, "CountContributions", "curator", "public")
+
+	if !reflect.DeepEqual(counts, map[string]int{"PatchSpecimen": 1}) {
+		t.Errorf("Public user counted contributions %v", counts)
+	}
+
+	h.okInto(&counts, "CountContributions", "curator", "curator")
+
+	if !reflect.DeepEqual(counts, map[string]int{"RegisterCollection": 1, "CreateSpecimen": 1, "PatchSpecimen": 1}) {
+		t.Errorf("Curator counted contributions %v", counts)
+	}
+
+	h.fail("QueryContributions", "curator", "nobody")
+}
+
+func TestQuerySpecimenContributors(t *testing.T) {
+	h := newContractHarness(t)
+	registerHerpetology(h)
+	h.ok("PatchSpecimen", `{"guid":"h1","updater":"curator","preparation":"skin"}`)
+
+	contributors := []Contributor{}
+	h.okInto(&contributors, "QuerySpecimenContributors", "h1", "curator")
+
+	if len(contributors) != 1 || contributors[0].Username != "curator" || contributors[0].Contributions != 2 {
+		t.Errorf("Specimen contributors are %+v", contributors)
+	}
+
+	h.fail("QuerySpecimenContributors", "h1", "public")
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	auxiliaryStatusLinked   = "linked"
+	auxiliaryStatusUnlinked = "unlinked"
+)
+
+var auxiliaryMediaTypes = []string{"audio", "image", "video", "document", "dataset", "other"}
+
+// Auxiliary describes a file kept off-chain, such as a bird song recording. The ledger holds its SHA-256 hash so that anyone can check that a copy of the file is the one that was linked.
+type Auxiliary struct {
+	ID          string `json:"id"`
+	Guid        string `json:"guid"`
+	Collection  string `json:"collection"`
+	MediaType   string `json:"mediaType"`
+	ContentHash string `json:"contentHash"`
+	Size        int64  `json:"size"`
+	MimeType    string `json:"mimeType"`
+	URI         string `json:"uri"`
+	License     string `json:"license"`
+	Creator     string `json:"creator"`
+	Status      string `json:"status"`
+	Linker      string `json:"linker"`
+	Linked      string `json:"linked"`
+	Unlinker    string `json:"unlinker"`
+	Unlinked    string `json:"unlinked"`
+}
+
+// normalizeContentHash checks that a hash is a hex encoded SHA-256 digest and returns it in lower case
+func normalizeContentHash(contentHash string) (string, error) {
+	digest, err := hex.DecodeString(contentHash)
+
+	if err != nil || len(digest) != 32 {
+		return "", fmt.Errorf("Content hash %s is not a hex encoded SHA-256 digest", contentHash)
+	}
+
+	return hex.EncodeToString(digest), nil
+}
+
+func getAuxiliary(ctx contractapi.TransactionContextInterface, auxiliaryID string) (*Auxiliary, error) {
+	auxiliaryBytes, err := getState(ctx, auxiliaryObjectType, auxiliaryID)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if auxiliaryBytes == nil {
+		return nil, fmt.Errorf("Auxiliary resource %s does not exist", auxiliaryID)
+	}
+
+	auxiliary := new(Auxiliary)
+	_ = json.Unmarshal(auxiliaryBytes, auxiliary)
+
+	return auxiliary, nil
+}
+
+func putAuxiliary(ctx contractapi.TransactionContextInterface, auxiliary *Auxiliary) error {
+	err := putIndex(ctx, auxiliary.Status == auxiliaryStatusLinked, specimenAuxiliaryIndex, auxiliary.Guid, auxiliary.ID)
+
+	if err != nil {
+		return err
+	}
+
+	auxiliaryBytes, _ := json.Marshal(auxiliary)
+
+	err = putState(ctx, auxiliaryObjectType, auxiliary.ID, auxiliaryBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// linkAuxiliaryAccess checks that the user's role in a collection is within its linkAuxiliary permission rule and returns the user
+func linkAuxiliaryAccess(ctx contractapi.TransactionContextInterface, collection string, username string) (*User, error) {
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	collect, err := getCollection(ctx, collection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	role := roleIn(user, collection)
+
+	if !strings.Contains(collect.LinkAuxiliary, role) {
+		return nil, fmt.Errorf("%s has role %s but role %s is required to link auxiliary resources", user.Username, role, collect.LinkAuxiliary)
+	}
+
+	return user, nil
+}
+
+func (s *SmartContract) LinkAuxiliary(ctx contractapi.TransactionContextInterface, guid string, username string, mediaType string, contentHash string, size int64, mimeType string, uri string, license string, creator string) (*Auxiliary, error) {
+	specimen, err := getSpecimen(ctx, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := linkAuxiliaryAccess(ctx, specimen.Collection, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	username = user.Username
+
+	validMediaType := false
+	for _, auxiliaryMediaType := range auxiliaryMediaTypes {
+		validMediaType = validMediaType || mediaType == auxiliaryMediaType
+	}
+	if !validMediaType {
+		return nil, fmt.Errorf("%s is not a valid media type. Valid media types are %s", mediaType, strings.Join(auxiliaryMediaTypes, ", "))
+	}
+
+	contentHash, err = normalizeContentHash(contentHash)
+
+	if err != nil {
+		return nil, err
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("Size %d is negative", size)
+	}
+	if uri == "" {
+		return nil, fmt.Errorf("An auxiliary resource requires the URI it is kept at")
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	auxiliary := Auxiliary{ctx.GetStub().GetTxID(), guid, specimen.Collection, mediaType, contentHash, size, mimeType, uri, license, creator, auxiliaryStatusLinked, username, now.Format(time.RFC3339), "", ""}
+
+	err = putAuxiliary(ctx, &auxiliary)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attributionString := fmt.Sprintf("Linked %s %s to specimen with GUID %s", mediaType, auxiliary.ID, guid)
+	err = attribute(ctx, username, "LinkAuxiliary", auxiliaryObjectType, auxiliary.ID, guid, attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "LinkAuxiliary", ObjectType: auxiliaryObjectType, ID: auxiliary.ID, Actor: username, ChangedFields: changedFields(Auxiliary{}, auxiliary), AffectedIDs: []string{guid}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &auxiliary, nil
+}
+
+// UnlinkAuxiliary detaches an auxiliary resource from its specimen, keeping its record so that the ledger still shows what was once linked
+func (s *SmartContract) UnlinkAuxiliary(ctx contractapi.TransactionContextInterface, auxiliaryID string, username string) (*Auxiliary, error) {
+	auxiliary, err := getAuxiliary(ctx, auxiliaryID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := linkAuxiliaryAccess(ctx, auxiliary.Collection, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	username = user.Username
+
+	if auxiliary.Status != auxiliaryStatusLinked {
+		return nil, fmt.Errorf("Auxiliary resource %s is already %s", auxiliaryID, auxiliary.Status)
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	oldAuxiliary := *auxiliary
+
+	auxiliary.Status = auxiliaryStatusUnlinked
+	auxiliary.Unlinker = username
+	auxiliary.Unlinked = now.Format(time.RFC3339)
+
+	err = putAuxiliary(ctx, auxiliary)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attributionString := fmt.Sprintf("Unlinked %s %s from specimen with GUID %s", auxiliary.MediaType, auxiliaryID, auxiliary.Guid)
+	err = attribute(ctx, username, "UnlinkAuxiliary", auxiliaryObjectType, auxiliaryID, auxiliary.Guid, attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "UnlinkAuxiliary", ObjectType: auxiliaryObjectType, ID: auxiliaryID, Actor: username, ChangedFields: changedFields(&oldAuxiliary, auxiliary), AffectedIDs: []string{auxiliary.Guid}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return auxiliary, nil
+}
+
+func (s *SmartContract) QueryAuxiliary(ctx contractapi.TransactionContextInterface, auxiliaryID string, username string) (*Auxiliary, error) {
+	auxiliary, err := getAuxiliary(ctx, auxiliaryID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = collectionQueryAccess(ctx, auxiliary.Collection, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return auxiliary, nil
+}
+
+// QuerySpecimenAuxiliaries returns the auxiliary resources currently linked to a specimen
+func (s *SmartContract) QuerySpecimenAuxiliaries(ctx contractapi.TransactionContextInterface, guid string, username string) ([]Auxiliary, error) {
+	specimen, err := getSpecimen(ctx, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = collectionQueryAccess(ctx, specimen.Collection, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	auxiliaryIDs, err := indexedIDs(ctx, specimenAuxiliaryIndex, []string{guid})
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := []Auxiliary{}
+
+	for _, auxiliaryID := range auxiliaryIDs {
+		auxiliary, err := getAuxiliary(ctx, auxiliaryID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, *auxiliary)
+	}
+
+	return results, nil
+}
+
+// VerifyAuxiliary reports whether the SHA-256 hash of a client's copy of a file matches the hash recorded when the file was linked
+func (s *SmartContract) VerifyAuxiliary(ctx contractapi.TransactionContextInterface, auxiliaryID string, username string, contentHash string) (bool, error) {
+	auxiliary, err := s.QueryAuxiliary(ctx, auxiliaryID, username)
+
+	if err != nil {
+		return false, err
+	}
+
+	contentHash, err = normalizeContentHash(contentHash)
+
+	if err != nil {
+		return false, err
+	}
+
+	return contentHash == auxiliary.ContentHash, nil
+}
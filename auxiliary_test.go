@@ -0,0 +1,68 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const emptyFileHash = "E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855"
+
+func TestLinkAuxiliary(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.fail("LinkAuxiliary", "0", "assistant", "song", emptyFileHash, "1024", "audio/wav", "https://example.org/0.wav", "CC-BY", "J Doe")
+	h.fail("LinkAuxiliary", "0", "assistant", "audio", "abc", "1024", "audio/wav", "https://example.org/0.wav", "CC-BY", "J Doe")
+	h.fail("LinkAuxiliary", "0", "public", "audio", emptyFileHash, "1024", "audio/wav", "https://example.org/0.wav", "CC-BY", "J Doe")
+
+	auxiliary := Auxiliary{}
+	h.okInto(&auxiliary, "LinkAuxiliary", "0", "assistant", "audio", emptyFileHash, "1024", "audio/wav", "https://example.org/0.wav", "CC-BY", "J Doe")
+
+	if auxiliary.Guid != "0" || auxiliary.Collection != "KU Ornithology" || auxiliary.ContentHash != strings.ToLower(emptyFileHash) || auxiliary.Status != auxiliaryStatusLinked || auxiliary.Linker != "assistant" {
+		t.Errorf("Linked auxiliary is %+v", auxiliary)
+	}
+
+	linked := []Auxiliary{}
+	h.okInto(&linked, "QuerySpecimenAuxiliaries", "0", "public")
+
+	if len(linked) != 1 || linked[0].ID != auxiliary.ID {
+		t.Errorf("Specimen auxiliaries are %+v", linked)
+	}
+}
+
+func TestVerifyAuxiliary(t *testing.T) {
+	h := newContractHarness(t)
+
+	auxiliary := Auxiliary{}
+	h.okInto(&auxiliary, "LinkAuxiliary", "0", "assistant", "audio", emptyFileHash, "0", "audio/wav", "https://example.org/0.wav", "CC-BY", "J Doe")
+
+	if verified := h.ok("VerifyAuxiliary", auxiliary.ID, "public", strings.ToLower(emptyFileHash)); verified != "true" {
+		t.Errorf("Matching hash verified as %s", verified)
+	}
+	if verified := h.ok("VerifyAuxiliary", auxiliary.ID, "public", strings.Repeat("0", 64)); verified != "false" {
+		t.Errorf("Differing hash verified as %s", verified)
+	}
+}
+
+func TestUnlinkAuxiliary(t *testing.T) {
+	h := newContractHarness(t)
+
+	auxiliary := Auxiliary{}
+	h.okInto(&auxiliary, "LinkAuxiliary", "0", "assistant", "image", emptyFileHash, "0", "image/png", "https://example.org/0.png", "CC-BY", "J Doe")
+
+	h.fail("UnlinkAuxiliary", auxiliary.ID, "public")
+	h.ok("UnlinkAuxiliary", auxiliary.ID, "assistant")
+	h.fail("UnlinkAuxiliary", auxiliary.ID, "assistant")
+
+	linked := []Auxiliary{}
+	h.okInto(&linked, "QuerySpecimenAuxiliaries", "0", "public")
+
+	if len(linked) != 0 {
+		t.Errorf("Unlinked auxiliary is still linked: %+v", linked)
+	}
+
+	h.okInto(&auxiliary, "QueryAuxiliary", auxiliary.ID, "public")
+
+	if auxiliary.Status != auxiliaryStatusUnlinked || auxiliary.Unlinker != "assistant" {
+		t.Errorf("Unlinked auxiliary is %+v", auxiliary)
+	}
+}
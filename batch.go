@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	batchRowCreated    = "created"
+	batchRowFailed     = "failed"
+	batchRowNotCreated = "notCreated"
+
+	//Larger batches risk exceeding the orderer's maximum transaction size
+	maxBatchSize = 500
+)
+
+type BatchRowResult struct {
+	Row    int    `json:"row"`
+	Guid   string `json:"guid"`
+	Status string `json:"status"`
+	Error  string `json:"error"`
+
+	FieldErrors []FieldError `json:"fieldErrors"`
+}
+
+type BatchResult struct {
+	Created int              `json:"created"`
+	Failed  int              `json:"failed"`
+	Rows    []BatchRowResult `json:"rows"`
+}
+
+// repeatedNumbers reports the catalog and accession numbers of a row which an earlier row of the same batch already uses
+func repeatedNumbers(seenNumbers map[string]int, row int, guid string, specimen *Specimen) error {
+	errors := []FieldError{}
+	numbers := map[string]string{"catalogNumber": specimen.CatalogNumber, "accessionNumber": specimen.AccessionNumber}
+
+	for _, field := range []string{"catalogNumber", "accessionNumber"} {
+		if numbers[field] == "" {
+			continue
+		}
+
+		key := strings.Join([]string{field, specimen.Collection, numbers[field]}, "\x00")
+
+		if first, ok := seenNumbers[key]; ok {
+			errors = append(errors, FieldError{field, numbers[field], fmt.Sprintf("is repeated from row %d", first)})
+		} else {
+			seenNumbers[key] = row
+		}
+	}
+
+	if len(errors) > 0 {
+		return &ValidationError{guid, errors}
+	}
+
+	return nil
+}
+
+// checkBatchRow checks that a row of a batch may be created, minting its guid if it has none
+func checkBatchRow(ctx contractapi.TransactionContextInterface, row int, patch *SpecimenPatch, specimen *Specimen, seen map[string]int, seenNumbers map[string]int) error {
+	if patch.Guid == "" {
+		collect, err := getCollection(ctx, specimen.Collection)
+
+		if err != nil {
+			return err
+		}
+
+		patch.Guid = mintGuid(ctx, collect, row)
+	}
+
+	if first, ok := seen[patch.Guid]; ok {
+		return fmt.Errorf("%s is repeated from row %d", patch.Guid, first)
+	}
+
+	seen[patch.Guid] = row
+
+	_, _, err := createAccess(ctx, patch.Guid, specimen, patch.conditionDate())
+
+	if err != nil {
+		return err
+	}
+
+	return repeatedNumbers(seenNumbers, row, patch.Guid, specimen)
+}
+
+// CreateSpecimens creates every specimen in a JSON array of SpecimenPatch objects, minting guids for those without one.
+// In best effort mode the rows which pass their checks are created even if others fail. Otherwise nothing is created unless every row passes.
+func (s *SmartContract) CreateSpecimens(ctx contractapi.TransactionContextInterface, specimenPatches string, bestEffort bool) (*BatchResult, error) {
+	patches := []SpecimenPatch{}
+
+	err := json.Unmarshal([]byte(specimenPatches), &patches)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal specimens. %s", err.Error())
+	}
+
+	if len(patches) == 0 {
+		return nil, fmt.Errorf("No specimens to create")
+	}
+
+	if len(patches) > maxBatchSize {
+		return nil, fmt.Errorf("%d specimens is more than the %d which may be created by one transaction", len(patches), maxBatchSize)
+	}
+
+	result := BatchResult{Rows: []BatchRowResult{}}
+	specimens := make([]Specimen, len(patches))
+
+	//World state reads do not see this transaction's writes, so guids and numbers repeated within the batch are caught here
+	seen := make(map[string]int)
+	seenNumbers := make(map[string]int)
+
+	for i := range patches {
+		specimens[i] = Specimen{Updater: patches[i].Updater, VandalizedTransactions: []string{}}
+		patches[i].applyTo(&specimens[i])
+
+		err = checkBatchRow(ctx, i, &patches[i], &specimens[i], seen, seenNumbers)
+
+		row := BatchRowResult{i, patches[i].Guid, batchRowNotCreated, "", []FieldError{}}
+
+		if err != nil {
+			row.Status = batchRowFailed
+			row.Error = err.Error()
+			result.Failed += 1
+		}
+
+		if validationError, ok := err.(*ValidationError); ok {
+			row.FieldErrors = validationError.Errors
+		}
+
+		result.Rows = append(result.Rows, row)
+	}
+
+	if result.Failed > 0 && !bestEffort {
+		return &result, nil
+	}
+
+	affectedIDs := []string{}
+
+	for i := range result.Rows {
+		if result.Rows[i].Status == batchRowFailed {
+			continue
+		}
+
+		err = putNewSpecimen(ctx, "CreateSpecimens", patches[i].Guid, &specimens[i])
+
+		if err != nil {
+			return nil, err
+		}
+
+		result.Rows[i].Status = batchRowCreated
+		result.Created += 1
+		affectedIDs = append(affectedIDs, patches[i].Guid)
+	}
+
+	if result.Created == 0 {
+		return &result, nil
+	}
+
+	//Rows may name different updaters, so the event is credited to the caller, or to its identity when it is linked to no user
+	actor, err := resolveCaller(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if actor == "" {
+		actor, err = clientIdentityID(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "CreateSpecimens", ObjectType: specimenObjectType, Actor: actor, AffectedIDs: affectedIDs})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"testing"
+)
+
+// batchRows has a row repeating an earlier row's guid, a row for a specimen which already exists and a row by a user who may not create specimens
+const batchRows = `[{"guid":"b1","updater":"manager","collection":"KU Ornithology","taxon":"Pomacanthus imperator"},
+	{"guid":"b1","updater":"manager","collection":"KU Ornithology"},
+	{"guid":"0","updater":"manager","collection":"KU Ornithology"},
+	{"guid":"b2","updater":"student","collection":"KU Ornithology"},
+	{"guid":"b3","updater":"manager","collection":"KU Ornithology"}]`
+
+func batchStatuses(result BatchResult) []string {
+	statuses := []string{}
+	for _, row := range result.Rows {
+		statuses = append(statuses, row.Status)
+	}
+	return statuses
+}
+
+func TestCreateSpecimensCreatesNothingUnlessEveryRowPasses(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.fail("CreateSpecimens", "[]", "false")
+
+	result := BatchResult{}
+	h.okInto(&result, "CreateSpecimens", batchRows, "false")
+
+	if statuses := batchStatuses(result); result.Created != 0 || result.Failed != 3 || !equalGuids(statuses, batchRowNotCreated, batchRowFailed, batchRowFailed, batchRowFailed, batchRowNotCreated) {
+		t.Errorf("Batch result is %+v", result)
+	}
+
+	h.fail("Query", "b1", "manager")
+	h.fail("Query", "b3", "manager")
+}
+
+func TestCreateSpecimensInBestEffortMode(t *testing.T) {
+	h := newContractHarness(t)
+
+	result := BatchResult{}
+	h.okInto(&result, "CreateSpecimens", batchRows, "true")
+
+	if statuses := batchStatuses(result); result.Created != 2 || result.Failed != 3 || !equalGuids(statuses, batchRowCreated, batchRowFailed, batchRowFailed, batchRowFailed, batchRowCreated) {
+		t.Errorf("Batch result is %+v", result)
+	}
+
+	h.ok("Query", "b1", "manager")
+	h.ok("Query", "b3", "manager")
+	h.fail("Query", "b2", "manager")
+
+	event := h.lastEvent()
+
+	if event.Action != "CreateSpecimens" || !equalGuids(event.AffectedIDs, "b1", "b3") {
+		t.Errorf("Batch emitted %+v", event)
+	}
+}
+
+func TestCreateSpecimensCreditsEventToCaller(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.ok("CreateSpecimens", `[{"guid":"b1","updater":"manager","collection":"KU Ornithology"},{"guid":"b2","updater":"manager","collection":"KU Ornithology"}]`, "false")
+
+	if event := h.lastEvent(); event.Actor != "Org1MSP::CN=app,O=Org1" {
+		t.Errorf("Batch by an unlinked identity was credited to %s", event.Actor)
+	}
+
+	linkAs(h, "manager")
+	h.ok("CreateSpecimens", `[{"guid":"b3","updater":"","collection":"KU Ornithology"}]`, "false")
+
+	if event := h.lastEvent(); event.Actor != "manager" {
+		t.Errorf("Batch by manager was credited to %s", event.Actor)
+	}
+}
@@ -1,14 +1,11 @@
 package main
 
 import (
-	"bytes"
 	"encoding/json"
 	"fmt"
-	"strconv"
 	"strings"
 	"time"
 
-	"github.com/google/go-cmp/cmp"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
 )
 
@@ -40,6 +37,8 @@ type Specimen struct {
 	Notes                  string   `json:"notes"`
 	Image                  string   `json:"image"`
 	VandalizedTransactions []string `json:"vandalizedTransactions"`
+	//SHA-256 hash of the specimen's precise locality when it is withheld in a private data collection, leaving a generalized locality on the specimen
+	LocalityHash string `json:"localityHash"`
 }
 
 type Collection struct {
@@ -57,11 +56,23 @@ type Collection struct {
 	RegisterUse     string `json:"registerUse"`
 	Query           string `json:"query"`
 	FlagError       string `json:"flagError"`
+
+	//Rules specimens of the collection are validated against, set by SetCollectionValidation
+	CatalogNumberPattern string   `json:"catalogNumberPattern"`
+	DateFormats          []string `json:"dateFormats"`
+	RequiredFields       []string `json:"requiredFields"`
+
+	//Prefix of the guids minted for the collection's specimens, set by SetGuidPrefix. UUIDs are minted when it is blank.
+	GuidPrefix string `json:"guidPrefix"`
+
+	//Data withheld from the collection's lower roles, set by SetRedactionPolicy
+	Redaction RedactionPolicy `json:"redaction"`
 }
 
 type User struct {
 	Username   string            `json:"username"`
 	Membership map[string]string `json:"membership"`
+	Identities []string          `json:"identities"`
 }
 
 type QueryResult struct {
@@ -70,16 +81,40 @@ type QueryResult struct {
 }
 
 type PendingTransaction struct {
+	ID          string   `json:"id"`
 	Transaction string   `json:"transaction"`
 	Arguments   []string `json:"arguments"`
 	Suggester   string   `json:"suggester"`
 	Reason      string   `json:"reason"`
+	Created     string   `json:"created"`
+	Status      string   `json:"status"`
+	Resolver    string   `json:"resolver"`
+	Resolved    string   `json:"resolved"`
 }
 
+// Init seeds the ledger with a sample collection, users and specimen. Once the ledger has a config it returns without writing,
+// so that calling Init again cannot reset the identity mode or taxon validation, or unlink the sample users' identities.
 func (s *SmartContract) Init(ctx contractapi.TransactionContextInterface) error {
-	sampleCollection := Collection{"KU Ornithology", "M", "MC", "MCA", "MCA", "MCAS", "MCA", "MC", "MC", "MCA", "MCAS", "MCAS", "MCASP", "MCASP"}
+	existingConfig, err := getState(ctx, configObjectType, "")
+
+	if err != nil {
+		return fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+	if existingConfig != nil {
+		return nil
+	}
+
+	config := Config{identityModeCompatible, false}
+	configBytes, _ := json.Marshal(config)
+	err = putState(ctx, configObjectType, "", configBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put config to world state. %s", err.Error())
+	}
+
+	sampleCollection := Collection{"KU Ornithology", "M", "MC", "MCA", "MCA", "MCAS", "MCA", "MC", "MC", "MCA", "MCAS", "MCAS", "MCASP", "MCASP", "", []string{"MM/DD/YYYY"}, []string{}, "", RedactionPolicy{"", -1, false, false, false}}
 	collectionBytes, _ := json.Marshal(sampleCollection)
-	err := ctx.GetStub().PutState("KU Ornithology", collectionBytes)
+	err = putState(ctx, collectionObjectType, "KU Ornithology", collectionBytes)
 
 	if err != nil {
 		return fmt.Errorf("Failed to put collection to world state. %s", err.Error())
@@ -87,9 +122,9 @@ func (s *SmartContract) Init(ctx contractapi.TransactionContextInterface) error
 
 	managerMap := make(map[string]string)
 	managerMap["KU Ornithology"] = "M"
-	sampleManager := User{"manager", managerMap}
+	sampleManager := User{"manager", managerMap, []string{}}
 	managerBytes, _ := json.Marshal(sampleManager)
-	err = ctx.GetStub().PutState("manager", managerBytes)
+	err = putState(ctx, userObjectType, "manager", managerBytes)
 
 	if err != nil {
 		return fmt.Errorf("Failed to put manager to world state. %s", err.Error())
@@ -97,9 +132,9 @@ func (s *SmartContract) Init(ctx contractapi.TransactionContextInterface) error
 
 	curatorMap := make(map[string]string)
 	curatorMap["KU Ornithology"] = "C"
-	sampleCurator := User{"curator", curatorMap}
+	sampleCurator := User{"curator", curatorMap, []string{}}
 	curatorBytes, _ := json.Marshal(sampleCurator)
-	err = ctx.GetStub().PutState("curator", curatorBytes)
+	err = putState(ctx, userObjectType, "curator", curatorBytes)
 
 	if err != nil {
 		return fmt.Errorf("Failed to put curator to world state. %s", err.Error())
@@ -107,9 +142,9 @@ func (s *SmartContract) Init(ctx contractapi.TransactionContextInterface) error
 
 	assistantMap := make(map[string]string)
 	assistantMap["KU Ornithology"] = "A"
-	sampleAssistant := User{"assistant", assistantMap}
+	sampleAssistant := User{"assistant", assistantMap, []string{}}
 	assistantBytes, _ := json.Marshal(sampleAssistant)
-	err = ctx.GetStub().PutState("assistant", assistantBytes)
+	err = putState(ctx, userObjectType, "assistant", assistantBytes)
 
 	if err != nil {
 		return fmt.Errorf("Failed to put assistant to world state. %s", err.Error())
@@ -117,9 +152,9 @@ func (s *SmartContract) Init(ctx contractapi.TransactionContextInterface) error
 
 	studentMap := make(map[string]string)
 	studentMap["KU Ornithology"] = "S"
-	sampleStudent := User{"student", studentMap}
+	sampleStudent := User{"student", studentMap, []string{}}
 	studentBytes, _ := json.Marshal(sampleStudent)
-	err = ctx.GetStub().PutState("student", studentBytes)
+	err = putState(ctx, userObjectType, "student", studentBytes)
 
 	if err != nil {
 		return fmt.Errorf("Failed to put student to world state. %s", err.Error())
@@ -127,27 +162,27 @@ func (s *SmartContract) Init(ctx contractapi.TransactionContextInterface) error
 
 	publicMap := make(map[string]string)
 	publicMap["KU Ornithology"] = "P"
-	samplePublic := User{"public", publicMap}
+	samplePublic := User{"public", publicMap, []string{}}
 	publicBytes, _ := json.Marshal(samplePublic)
-	err = ctx.GetStub().PutState("public", publicBytes)
+	err = putState(ctx, userObjectType, "public", publicBytes)
 
 	if err != nil {
 		return fmt.Errorf("Failed to put public to world state. %s", err.Error())
 	}
 
-	sampleSpecimen := Specimen{"KU Ornithology", "manager", "32581", "2002-IC-062", "06/19/2003", "Bentley, Andy C", "Pygoplites diacanthus", "Greenfield, David W", "", "G02-15", "01/27/2002", "", "Fiji, Viti Levu", "18.1483325958", "-178.3984985352", "Barrier reef off Suva Point north of wreck in main channel", "", "", "", "", "", "", []string{}}
+	sampleSpecimen := Specimen{"KU Ornithology", "manager", "32581", "2002-IC-062", "06/19/2003", "Bentley, Andy C", "Pygoplites diacanthus", "Greenfield, David W", "", "G02-15", "01/27/2002", "", "Fiji, Viti Levu", "18.1483325958", "-178.3984985352", "Barrier reef off Suva Point north of wreck in main channel", "", "", "", "", "", "", []string{}, ""}
 	specimenBytes, _ := json.Marshal(sampleSpecimen)
-	err = ctx.GetStub().PutState("0", specimenBytes)
+	err = putState(ctx, specimenObjectType, "0", specimenBytes)
 
 	if err != nil {
 		return fmt.Errorf("Failed to put specimen to world state. %s", err.Error())
 	}
 
-	return nil
+	return indexSpecimen(ctx, "0", nil, &sampleSpecimen)
 }
 
 func (s *SmartContract) RegisterCollection(ctx contractapi.TransactionContextInterface, name string, username string, createSpecimen string, primaryUpdate string, secondaryUpdate string, georeference string, linkImages string, linkAuxiliary string, taxonName string, taxonClass string, suggestTaxon string, registerLoan string, registerUse string, query string, flagError string) error {
-	checkExistence, err := ctx.GetStub().GetState(name)
+	checkExistence, err := getState(ctx, collectionObjectType, name)
 
 	if err != nil {
 		return fmt.Errorf("Failed to read from world state. %s", err.Error())
@@ -156,42 +191,43 @@ func (s *SmartContract) RegisterCollection(ctx contractapi.TransactionContextInt
 		return fmt.Errorf("%s already exists", name)
 	}
 
-	checkUser, err := ctx.GetStub().GetState(username)
+	user, err := getUser(ctx, username)
 
 	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
-	}
-	if checkUser == nil {
-		return fmt.Errorf("%s does not exists", username)
+		return err
 	}
 
+	username = user.Username
+
 	attributionString := fmt.Sprintf("Registered Collection %s", name)
-	attributionBytes := []byte(attributionString)
-	err = ctx.GetStub().PutState(username+"|attribution", attributionBytes)
+	err = attribute(ctx, username, "RegisterCollection", collectionObjectType, name, "", attributionString)
 
 	if err != nil {
 		return fmt.Errorf("Failed to put to world state. %s", err.Error())
 	}
 
-	collection := Collection{name, createSpecimen, primaryUpdate, secondaryUpdate, georeference, linkImages, linkAuxiliary, taxonName, taxonClass, suggestTaxon, registerLoan, registerUse, query, flagError}
+	collection := Collection{name, createSpecimen, primaryUpdate, secondaryUpdate, georeference, linkImages, linkAuxiliary, taxonName, taxonClass, suggestTaxon, registerLoan, registerUse, query, flagError, "", []string{}, []string{}, "", RedactionPolicy{"", -1, false, false, false}}
 	collectionBytes, _ := json.Marshal(collection)
-	err = ctx.GetStub().PutState(name, collectionBytes)
+	err = putState(ctx, collectionObjectType, name, collectionBytes)
 
 	if err != nil {
 		return fmt.Errorf("Failed to put to world state. %s", err.Error())
 	}
 
-	user := new(User)
-	_ = json.Unmarshal(checkUser, user)
-
 	user.Membership[name] = "M"
 	userBytes, _ := json.Marshal(user)
-	return ctx.GetStub().PutState(username, userBytes)
+	err = putState(ctx, userObjectType, username, userBytes)
+
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, ChangeEvent{Action: "RegisterCollection", ObjectType: collectionObjectType, ID: name, Actor: username, ChangedFields: changedFields(Collection{}, collection)})
 
 }
 
 func (s *SmartContract) UpdateCollection(ctx contractapi.TransactionContextInterface, name string, username string, createSpecimen string, primaryUpdate string, secondaryUpdate string, georeference string, linkImages string, linkAuxiliary string, taxonName string, taxonClass string, suggestTaxon string, registerLoan string, registerUse string, query string, flagError string) error {
-	checkExistence, err := ctx.GetStub().GetState(name)
+	checkExistence, err := getState(ctx, collectionObjectType, name)
 
 	if err != nil {
 		return fmt.Errorf("Failed to read from world state. %s", err.Error())
@@ -200,21 +236,17 @@ func (s *SmartContract) UpdateCollection(ctx contractapi.TransactionContextInter
 		return fmt.Errorf("%s does not exists", name)
 	}
 
-	checkUser, err := ctx.GetStub().GetState(username)
+	user, err := getUser(ctx, username)
 
 	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
-	}
-	if checkUser == nil {
-		return fmt.Errorf("%s does not exists", username)
+		return err
 	}
 
+	username = user.Username
+
 	oldCollection := new(Collection)
 	_ = json.Unmarshal(checkExistence, oldCollection)
 
-	user := new(User)
-	_ = json.Unmarshal(checkUser, user)
-
 	if role, ok := user.Membership[name]; ok {
 		if role != "M" {
 			return fmt.Errorf("%s is not the Manager for collection %s", username, name)
@@ -265,20 +297,25 @@ func (s *SmartContract) UpdateCollection(ctx contractapi.TransactionContextInter
 	}
 
 	attributionString := fmt.Sprintf("Updated Collection %s access control policies", name)
-	attributionBytes := []byte(attributionString)
-	err = ctx.GetStub().PutState(username+"|attribution", attributionBytes)
+	err = attribute(ctx, username, "UpdateCollection", collectionObjectType, name, "", attributionString)
 
 	if err != nil {
 		return fmt.Errorf("Failed to put to world state. %s", err.Error())
 	}
 
-	collection := Collection{name, createSpecimen, primaryUpdate, secondaryUpdate, georeference, linkImages, linkAuxiliary, taxonName, taxonClass, suggestTaxon, registerLoan, registerUse, query, flagError}
+	collection := Collection{name, createSpecimen, primaryUpdate, secondaryUpdate, georeference, linkImages, linkAuxiliary, taxonName, taxonClass, suggestTaxon, registerLoan, registerUse, query, flagError, oldCollection.CatalogNumberPattern, oldCollection.DateFormats, oldCollection.RequiredFields, oldCollection.GuidPrefix, oldCollection.Redaction}
 	collectionBytes, _ := json.Marshal(collection)
-	return ctx.GetStub().PutState(name, collectionBytes)
+	err = putState(ctx, collectionObjectType, name, collectionBytes)
+
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, ChangeEvent{Action: "UpdateCollection", ObjectType: collectionObjectType, ID: name, Actor: username, ChangedFields: changedFields(oldCollection, &collection)})
 }
 
 func (s *SmartContract) RegisterUser(ctx contractapi.TransactionContextInterface, username string) error {
-	checkExistence, err := ctx.GetStub().GetState(username)
+	checkExistence, err := getState(ctx, userObjectType, username)
 
 	if err != nil {
 		return fmt.Errorf("Failed to read from world state. %s", err.Error())
@@ -287,10 +324,41 @@ func (s *SmartContract) RegisterUser(ctx contractapi.TransactionContextInterface
 		return fmt.Errorf("%s already exists", username)
 	}
 
+	config, err := getConfig(ctx)
+
+	if err != nil {
+		return err
+	}
+
 	emptyMap := make(map[string]string)
-	user := User{username, emptyMap}
+	user := User{username, emptyMap, []string{}}
+
+	//In strict mode the new user is bound to the identity registering it
+	if config.IdentityMode == identityModeStrict {
+		callerName, err := resolveCaller(ctx)
+
+		if err != nil {
+			return err
+		}
+		if callerName != "" && callerName != username {
+			return fmt.Errorf("Submitting identity is already linked to user %s", callerName)
+		}
+
+		err = linkIdentity(ctx, &user)
+
+		if err != nil {
+			return err
+		}
+	}
+
 	userBytes, _ := json.Marshal(user)
-	return ctx.GetStub().PutState(username, userBytes)
+	err = putState(ctx, userObjectType, username, userBytes)
+
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, ChangeEvent{Action: "RegisterUser", ObjectType: userObjectType, ID: username, Actor: username, ChangedFields: changedFields(User{}, user)})
 
 }
 
@@ -299,7 +367,7 @@ func (s *SmartContract) GrantPermission(ctx contractapi.TransactionContextInterf
 		return fmt.Errorf("%s is not a valid permission. Valid permissions are M, C, A, S, and P", permission)
 	}
 
-	checkUser, err := ctx.GetStub().GetState(username)
+	checkUser, err := getState(ctx, userObjectType, username)
 
 	if err != nil {
 		return fmt.Errorf("Failed to read from world state. %s", err.Error())
@@ -308,16 +376,15 @@ func (s *SmartContract) GrantPermission(ctx contractapi.TransactionContextInterf
 		return fmt.Errorf("%s does not exists", username)
 	}
 
-	checkGranter, err := ctx.GetStub().GetState(granterName)
+	granter, err := getUser(ctx, granterName)
 
 	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
-	}
-	if checkUser == nil {
-		return fmt.Errorf("%s does not exists", granterName)
+		return err
 	}
 
-	checkCollection, err := ctx.GetStub().GetState(collection)
+	granterName = granter.Username
+
+	checkCollection, err := getState(ctx, collectionObjectType, collection)
 
 	if err != nil {
 		return fmt.Errorf("Failed to read from world state. %s", err.Error())
@@ -329,9 +396,6 @@ func (s *SmartContract) GrantPermission(ctx contractapi.TransactionContextInterf
 	user := new(User)
 	_ = json.Unmarshal(checkUser, user)
 
-	granter := new(User)
-	_ = json.Unmarshal(checkGranter, granter)
-
 	role, ok := granter.Membership[collection]
 
 	granteeRole, granteeOk := user.Membership[collection]
@@ -355,8 +419,7 @@ func (s *SmartContract) GrantPermission(ctx contractapi.TransactionContextInterf
 	}
 
 	attributionString := fmt.Sprintf("Updated %s permission to %s in collection %s", username, permission, collection)
-	attributionBytes := []byte(attributionString)
-	err = ctx.GetStub().PutState(granterName+"|attribution", attributionBytes)
+	err = attribute(ctx, granterName, "GrantPermission", userObjectType, username, "", attributionString)
 
 	if err != nil {
 		return fmt.Errorf("Failed to put to world state. %s", err.Error())
@@ -364,402 +427,165 @@ func (s *SmartContract) GrantPermission(ctx contractapi.TransactionContextInterf
 
 	user.Membership[collection] = permission
 	userBytes, _ := json.Marshal(user)
-	return ctx.GetStub().PutState(username, userBytes)
-
-}
-
-func (s *SmartContract) Create(ctx contractapi.TransactionContextInterface, guid string, collection string, updater string, catalogNumber string, accessionNumber string, catalogDate string, cataloger string, taxon string, determiner string, determineDate string, fieldNumber string, fieldDate string, collector string, location string, latitude string, longitude string, habitat string, preparation string, condition string, notes string, image string) error {
-	checkExistence, err := ctx.GetStub().GetState(guid)
+	err = putState(ctx, userObjectType, username, userBytes)
 
 	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
-	}
-
-	if checkExistence != nil {
-		return fmt.Errorf("%s already exists", guid)
-	}
-
-	checkUpdater, err := ctx.GetStub().GetState(updater)
-
-	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
-	}
-
-	if checkUpdater == nil {
-		return fmt.Errorf("%s does not exist", updater)
-	}
-
-	checkCollection, err := ctx.GetStub().GetState(collection)
-
-	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
-	}
-	if checkCollection == nil {
-		return fmt.Errorf("%s does not exists", collection)
-	}
-
-	collect := new(Collection)
-	_ = json.Unmarshal(checkCollection, collect)
-
-	user := new(User)
-	_ = json.Unmarshal(checkUpdater, user)
-
-	role, ok := user.Membership[collection]
-
-	if !ok {
-		role = "P"
+		return err
 	}
 
-	if !strings.Contains(collect.CreateSpecimen, role) {
-		return fmt.Errorf("%s has role %s but role %s is required to create specimen", updater, role, collect.CreateSpecimen)
-	}
+	return emitEvent(ctx, ChangeEvent{Action: "GrantPermission", ObjectType: userObjectType, ID: username, Actor: granterName, ChangedFields: []string{"membership"}})
 
-	attributionString := fmt.Sprintf("Created Specimen with GUID %s", guid)
-	attributionBytes := []byte(attributionString)
-	err = ctx.GetStub().PutState(updater+"|attribution", attributionBytes)
-
-	if err != nil {
-		return fmt.Errorf("Failed to put to world state. %s", err.Error())
-	}
+}
 
-	specimen := Specimen{collection, updater, catalogNumber, accessionNumber, catalogDate, cataloger, taxon, determiner, determineDate, fieldNumber, fieldDate, collector, location, latitude, longitude, habitat, preparation, condition, "", "", notes, image, []string{}}
+func (s *SmartContract) Create(ctx contractapi.TransactionContextInterface, guid string, collection string, updater string, catalogNumber string, accessionNumber string, catalogDate string, cataloger string, taxon string, determiner string, determineDate string, fieldNumber string, fieldDate string, collector string, location string, latitude string, longitude string, habitat string, preparation string, condition string, notes string, image string) error {
+	specimen := Specimen{collection, updater, catalogNumber, accessionNumber, catalogDate, cataloger, taxon, determiner, determineDate, fieldNumber, fieldDate, collector, location, latitude, longitude, habitat, preparation, condition, "", "", notes, image, []string{}, ""}
 
-	specimenBytes, _ := json.Marshal(specimen)
+	_, err := s.create(ctx, "Create", guid, specimen, "")
 
-	return ctx.GetStub().PutState(guid, specimenBytes)
+	return err
 }
 
 func (s *SmartContract) Update(ctx contractapi.TransactionContextInterface, guid string, collection string, updater string, catalogNumber string, accessionNumber string, catalogDate string, cataloger string, taxon string, determiner string, determineDate string, fieldNumber string, fieldDate string, collector string, location string, latitude string, longitude string, habitat string, preparation string, condition string, conditionDate string, notes string, image string) error {
-	checkExistence, err := ctx.GetStub().GetState(guid)
-
-	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
-	}
-
-	if checkExistence == nil {
-		return fmt.Errorf("%s does not exists", guid)
-	}
-
-	checkUpdater, err := ctx.GetStub().GetState(updater)
-
-	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
-	}
-
-	if checkUpdater == nil {
-		return fmt.Errorf("%s does not exist", updater)
-	}
-
-	checkCollection, err := ctx.GetStub().GetState(collection)
-
-	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
-	}
-	if checkCollection == nil {
-		return fmt.Errorf("%s does not exists", collection)
-	}
-
-	collect := new(Collection)
-	_ = json.Unmarshal(checkCollection, collect)
-
-	user := new(User)
-	_ = json.Unmarshal(checkUpdater, user)
-
-	role, ok := user.Membership[collection]
-
-	if !ok {
-		role = "P"
-	}
-
-	oldSpecimen := new(Specimen)
-	_ = json.Unmarshal(checkExistence, oldSpecimen)
+	return s.update(ctx, "Update", guid, collection, updater, catalogNumber, accessionNumber, catalogDate, cataloger, taxon, determiner, determineDate, fieldNumber, fieldDate, collector, location, latitude, longitude, habitat, preparation, condition, conditionDate, notes, image)
+}
 
+// update applies an Update and emits its event under the name of the transaction that caused it
+func (s *SmartContract) update(ctx contractapi.TransactionContextInterface, action string, guid string, collection string, updater string, catalogNumber string, accessionNumber string, catalogDate string, cataloger string, taxon string, determiner string, determineDate string, fieldNumber string, fieldDate string, collector string, location string, latitude string, longitude string, habitat string, preparation string, condition string, conditionDate string, notes string, image string) error {
 	//Don't overwrite existing data with blank data
-	if collection == "" {
-		collection = oldSpecimen.Collection
-	}
-	if catalogNumber == "" {
-		catalogNumber = oldSpecimen.CatalogNumber
-	}
-	if accessionNumber == "" {
-		accessionNumber = oldSpecimen.AccessionNumber
-	}
-	if catalogDate == "" {
-		catalogDate = oldSpecimen.CatalogDate
-	}
-	if cataloger == "" {
-		cataloger = oldSpecimen.Cataloger
-	}
-	if taxon == "" {
-		taxon = oldSpecimen.Taxon
-	}
-	if determiner == "" {
-		determiner = oldSpecimen.Determiner
-	}
-	if determineDate == "" {
-		determineDate = oldSpecimen.DetermineDate
-	}
-	if fieldNumber == "" {
-		fieldNumber = oldSpecimen.FieldNumber
-	}
-	if fieldDate == "" {
-		fieldDate = oldSpecimen.FieldDate
-	}
-	if collector == "" {
-		collector = oldSpecimen.Collector
-	}
-	if location == "" {
-		location = oldSpecimen.Location
-	}
-	if latitude == "" {
-		latitude = oldSpecimen.Latitude
-	}
-	if longitude == "" {
-		longitude = oldSpecimen.Longitude
-	}
-	if habitat == "" {
-		habitat = oldSpecimen.Habitat
-	}
-	if preparation == "" {
-		preparation = oldSpecimen.Preparation
-	}
-	if condition != "" {
-		condition = oldSpecimen.Condition + condition + " " + conditionDate + "\n"
-	} else {
-		condition = oldSpecimen.Condition
-	}
-	if notes != "" {
-		notes = oldSpecimen.Notes + notes + "\n"
-	} else {
-		notes = oldSpecimen.Notes
-	}
-	if image == "" {
-		image = oldSpecimen.Image
-	}
+	patch := SpecimenPatch{guid, updater, optional(collection), optional(catalogNumber), optional(accessionNumber), optional(catalogDate), optional(cataloger), optional(taxon), optional(determiner), optional(determineDate), optional(fieldNumber), optional(fieldDate), optional(collector), optional(location), optional(latitude), optional(longitude), optional(habitat), optional(preparation), condition, conditionDate, notes, optional(image), "", "", "", ""}
 
-	if collection != oldSpecimen.Collection {
-		return fmt.Errorf("collection %s does not match existing specimen collection %s", collection, oldSpecimen.Collection)
-	}
-
-	if catalogNumber != oldSpecimen.CatalogNumber || accessionNumber != oldSpecimen.AccessionNumber || catalogDate != oldSpecimen.CatalogDate || cataloger != oldSpecimen.Cataloger || fieldNumber != oldSpecimen.FieldNumber || fieldDate != oldSpecimen.FieldDate || collector != oldSpecimen.Collector {
-		if !strings.Contains(collect.PrimaryUpdate, role) {
-			return fmt.Errorf("%s has role %s but role %s is required to update primary info", updater, role, collect.PrimaryUpdate)
-		}
-	}
-
-	if location != oldSpecimen.Location || latitude != oldSpecimen.Latitude || longitude != oldSpecimen.Longitude || habitat != oldSpecimen.Habitat {
-		if !strings.Contains(collect.Georeference, role) {
-			return fmt.Errorf("%s has role %s but role %s is required to update geolocation info", updater, role, collect.Georeference)
-		}
-	}
+	_, _, err := s.patchSpecimen(ctx, action, &patch)
 
-	if preparation != oldSpecimen.Preparation || condition != oldSpecimen.Condition || notes != oldSpecimen.Notes {
-		if !strings.Contains(collect.SecondaryUpdate, role) {
-			return fmt.Errorf("%s has role %s but role %s is required to update secondary info", updater, role, collect.SecondaryUpdate)
-		}
-	}
-
-	if taxon != oldSpecimen.Taxon || determiner != oldSpecimen.Determiner || determineDate != oldSpecimen.DetermineDate {
-		if !strings.Contains(collect.TaxonName, role) {
-			return fmt.Errorf("%s has role %s but role %s is required to update taxon name", updater, role, collect.TaxonName)
-		}
-	}
-
-	if image != oldSpecimen.Image {
-		if !strings.Contains(collect.LinkImages, role) {
-			return fmt.Errorf("%s has role %s but role %s is required to link images", updater, role, collect.LinkImages)
-		}
-	}
-
-	specimen := Specimen{collection, updater, catalogNumber, accessionNumber, catalogDate, cataloger, taxon, determiner, determineDate, fieldNumber, fieldDate, collector, location, latitude, longitude, habitat, preparation, condition, oldSpecimen.Loans, oldSpecimen.Grants, notes, image, oldSpecimen.VandalizedTransactions}
-
-	//Check if an actual change was made
-	specimen.Updater = oldSpecimen.Updater
-	if cmp.Equal(specimen, *oldSpecimen) {
-		return fmt.Errorf("Updated specimen is equivalent to old specimen. Operation aborted to conserve blockchain resources")
-	}
-	specimen.Updater = updater
-
-	attributionString := fmt.Sprintf("Updated Specimen with GUID %s", guid)
-	attributionBytes := []byte(attributionString)
-	err = ctx.GetStub().PutState(updater+"|attribution", attributionBytes)
-
-	if err != nil {
-		return fmt.Errorf("Failed to put to world state. %s", err.Error())
-	}
-
-	specimenBytes, _ := json.Marshal(specimen)
-
-	return ctx.GetStub().PutState(guid, specimenBytes)
+	return err
 }
 
 func (s *SmartContract) SuggestUpdate(ctx contractapi.TransactionContextInterface, guid string, collection string, updater string, catalogNumber string, accessionNumber string, catalogDate string, cataloger string, taxon string, determiner string, determineDate string, fieldNumber string, fieldDate string, collector string, location string, latitude string, longitude string, habitat string, preparation string, condition string, conditionDate string, notes string, image string, reason string) error {
-	checkExistence, err := ctx.GetStub().GetState(guid)
+	collection, updater, err := suggestionAccess(ctx, guid, collection, updater)
 
 	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
+		return err
 	}
 
-	if checkExistence == nil {
-		return fmt.Errorf("%s does not exists", guid)
-	}
-
-	checkUpdater, err := ctx.GetStub().GetState(updater)
-
-	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
-	}
-
-	if checkUpdater == nil {
-		return fmt.Errorf("%s does not exist", updater)
-	}
+	_, err = addPendingTransaction(ctx, "SuggestUpdate", guid, updater, "Update", []string{guid, collection, updater, catalogNumber, accessionNumber, catalogDate, cataloger, taxon, determiner, determineDate, fieldNumber, fieldDate, collector, location, latitude, longitude, habitat, preparation, condition, conditionDate, notes, image}, reason)
 
-	if collection == "" {
-		specimen := new(Specimen)
-		_ = json.Unmarshal(checkExistence, specimen)
-		collection = specimen.Collection
-	}
+	return err
+}
 
-	checkCollection, err := ctx.GetStub().GetState(collection)
+func (s *SmartContract) ApproveTransaction(ctx contractapi.TransactionContextInterface, guid string, username string, transactionID string) error {
+	transactions, err := getPendingTransactions(ctx, guid)
 
 	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
-	}
-	if checkCollection == nil {
-		return fmt.Errorf("%s does not exists", collection)
+		return err
 	}
 
-	collect := new(Collection)
-	_ = json.Unmarshal(checkCollection, collect)
-
-	user := new(User)
-	_ = json.Unmarshal(checkUpdater, user)
+	index, err := pendingTransactionIndex(transactions, guid, transactionID)
 
-	role, ok := user.Membership[collection]
-
-	if !ok {
-		role = "P"
-	}
-
-	if !strings.Contains(collect.FlagError, role) {
-		return fmt.Errorf("%s has role %s but role %s is required to suggest updates", updater, role, collect.FlagError)
-	}
-
-	checkPendingTransactions, err := ctx.GetStub().GetState("pending" + guid)
 	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
+		return err
 	}
 
-	pendingTransactions := []PendingTransaction{}
+	transaction := transactions[index]
 
-	if checkPendingTransactions != nil {
-		_ = json.Unmarshal(checkPendingTransactions, &pendingTransactions)
-	}
+	if transaction.Transaction == "Update" {
+		args := transaction.Arguments
 
-	pendingTransaction := PendingTransaction{"Update", []string{guid, collection, updater, catalogNumber, accessionNumber, catalogDate, cataloger, taxon, determiner, determineDate, fieldNumber, fieldDate, collector, location, latitude, longitude, habitat, preparation, condition, conditionDate, notes, image}, updater, reason}
+		notes := args[20]
+		if notes == "" {
+			notes = "Approved update suggested by user " + args[2]
+		} else {
+			notes = notes + "\n" + "Approved update suggested by user " + args[2]
+		}
 
-	pendingTransactions = append(pendingTransactions, pendingTransaction)
+		user, err := getUser(ctx, username)
 
-	pendingTransactionsBytes, _ := json.Marshal(pendingTransactions)
+		if err != nil {
+			return err
+		}
 
-	attributionString := fmt.Sprintf("Suggested update to specimen with GUID %s", guid)
-	attributionBytes := []byte(attributionString)
-	err = ctx.GetStub().PutState(updater+"|attribution", attributionBytes)
+		username = user.Username
 
-	return ctx.GetStub().PutState("pending"+guid, pendingTransactionsBytes)
+		attributionString := fmt.Sprintf("Approved suggested update to specimen with GUID %s", guid)
+		err = attribute(ctx, username, "ApproveTransaction", pendingObjectType, guid, guid, attributionString)
 
-}
+		if err != nil {
+			return fmt.Errorf("Failed to put to world state. %s", err.Error())
+		}
 
-func (s *SmartContract) ApproveTransaction(ctx contractapi.TransactionContextInterface, guid string, username string, transactionIndex string) error {
-	checkTransactions, err := ctx.GetStub().GetState("pending" + guid)
+		err = resolvePendingTransaction(ctx, guid, transactions, index, pendingStatusApproved, username)
 
-	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
-	}
+		if err != nil {
+			return err
+		}
 
-	if checkTransactions == nil {
-		return fmt.Errorf("Pending transaction for %s does not exists", guid)
-	}
+		return s.update(ctx, "ApproveTransaction", args[0], args[1], username, args[3], args[4], args[5], args[6], args[7], args[8], args[9], args[10], args[11], args[12], args[13], args[14], args[15], args[16], args[17], args[18], args[19], notes, args[21])
 
-	transactions := []PendingTransaction{}
-	_ = json.Unmarshal(checkTransactions, &transactions)
+	}
 
-	index, err := strconv.Atoi(transactionIndex)
+	if transaction.Transaction == "Patch" {
+		patch, err := parsePatch(transaction.Arguments[0])
 
-	if err != nil {
-		return fmt.Errorf("Error. Provided pending transaction index is not an integer. %s", err.Error())
-	}
+		if err != nil {
+			return err
+		}
 
-	if index < 0 || index >= len(transactions) {
-		return fmt.Errorf("Error. Provided pending transaction index does not correspond to an existing pending transaction.")
-	}
+		user, err := getUser(ctx, username)
 
-	transaction := transactions[index]
+		if err != nil {
+			return err
+		}
 
-	if transaction.Transaction == "Update" {
-		args := transaction.Arguments
+		username = user.Username
 
-		notes := args[20]
-		if notes == "" {
-			notes = "Approved update suggested by user " + args[2]
+		if patch.Notes == "" {
+			patch.Notes = "Approved update suggested by user " + transaction.Suggester
 		} else {
-			notes = notes + "\n" + "Approved update suggested by user " + args[2]
+			patch.Notes = patch.Notes + "\n" + "Approved update suggested by user " + transaction.Suggester
 		}
+		patch.Updater = username
 
 		attributionString := fmt.Sprintf("Approved suggested update to specimen with GUID %s", guid)
-		attributionBytes := []byte(attributionString)
-		err = ctx.GetStub().PutState(username+"|attribution", attributionBytes)
+		err = attribute(ctx, username, "ApproveTransaction", pendingObjectType, guid, guid, attributionString)
 
-		//remove the approved transaction from the list of pending transactions
-		transactions = append(transactions[:index], transactions[index+1:]...)
-		transactionsBytes, _ := json.Marshal(transactions)
-		ctx.GetStub().PutState("pending"+guid, transactionsBytes)
+		if err != nil {
+			return fmt.Errorf("Failed to put to world state. %s", err.Error())
+		}
 
-		return s.Update(ctx, args[0], args[1], username, args[3], args[4], args[5], args[6], args[7], args[8], args[9], args[10], args[11], args[12], args[13], args[14], args[15], args[16], args[17], args[18], args[19], notes, args[21])
+		err = resolvePendingTransaction(ctx, guid, transactions, index, pendingStatusApproved, username)
 
+		if err != nil {
+			return err
+		}
+
+		_, _, err = s.patchSpecimen(ctx, "ApproveTransaction", patch)
+
+		return err
 	}
 
 	return fmt.Errorf("Error, pending transaction name not valid.")
 }
 
-func (s *SmartContract) DenyTransaction(ctx contractapi.TransactionContextInterface, guid string, username string, transactionIndex string) error {
-	checkTransactions, err := ctx.GetStub().GetState("pending" + guid)
+func (s *SmartContract) DenyTransaction(ctx contractapi.TransactionContextInterface, guid string, username string, transactionID string) error {
+	transactions, err := getPendingTransactions(ctx, guid)
 
 	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
-	}
-
-	if checkTransactions == nil {
-		return fmt.Errorf("Pending transaction for %s does not exists", guid)
+		return err
 	}
 
-	transactions := []PendingTransaction{}
-	_ = json.Unmarshal(checkTransactions, &transactions)
-
-	index, err := strconv.Atoi(transactionIndex)
+	index, err := pendingTransactionIndex(transactions, guid, transactionID)
 
 	if err != nil {
-		return fmt.Errorf("Error. Provided pending transaction indexd is not an integer. %s", err.Error())
-	}
-
-	if index < 0 || index >= len(transactions) {
-		return fmt.Errorf("Error. Provided pending transaction index does not correspond to an existing pending transaction.")
+		return err
 	}
 
-	checkUser, err := ctx.GetStub().GetState(username)
+	user, err := getUser(ctx, username)
 
 	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
+		return err
 	}
 
-	if checkUser == nil {
-		return fmt.Errorf("%s does not exist", username)
-	}
+	username = user.Username
 
-	user := new(User)
-	_ = json.Unmarshal(checkUser, user)
-
-	checkSpecimen, err := ctx.GetStub().GetState(guid)
+	checkSpecimen, err := getState(ctx, specimenObjectType, guid)
 
 	if err != nil {
 		return fmt.Errorf("Failed to read from world state. %s", err.Error())
@@ -774,7 +600,7 @@ func (s *SmartContract) DenyTransaction(ctx contractapi.TransactionContextInterf
 
 	collection := specimen.Collection
 
-	checkCollection, err := ctx.GetStub().GetState(collection)
+	checkCollection, err := getState(ctx, collectionObjectType, collection)
 
 	if err != nil {
 		return fmt.Errorf("Failed to read from world state. %s", err.Error())
@@ -797,13 +623,17 @@ func (s *SmartContract) DenyTransaction(ctx contractapi.TransactionContextInterf
 		return fmt.Errorf("%s has role %s but role %s is required to update primary info", username, role, collect.PrimaryUpdate)
 	}
 
-	transactions = append(transactions[:index], transactions[index+1:]...)
-	transactionsBytes, _ := json.Marshal(transactions)
-	return ctx.GetStub().PutState("pending"+guid, transactionsBytes)
+	err = resolvePendingTransaction(ctx, guid, transactions, index, pendingStatusDenied, username)
+
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, ChangeEvent{Action: "DenyTransaction", ObjectType: pendingObjectType, ID: guid, Actor: username, ChangedFields: []string{"status"}, AffectedIDs: []string{transactionID}})
 }
 
 func (s *SmartContract) Override(ctx contractapi.TransactionContextInterface, guid string, username string, condition string, loans string, grants string, notes string) error {
-	checkExistence, err := ctx.GetStub().GetState(guid)
+	checkExistence, err := getState(ctx, specimenObjectType, guid)
 
 	if err != nil {
 		return fmt.Errorf("Failed to read from world state. %s", err.Error())
@@ -813,26 +643,21 @@ func (s *SmartContract) Override(ctx contractapi.TransactionContextInterface, gu
 		return fmt.Errorf("%s does not exists", guid)
 	}
 
-	checkUser, err := ctx.GetStub().GetState(username)
+	user, err := getUser(ctx, username)
 
 	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
+		return err
 	}
 
-	if checkUser == nil {
-		return fmt.Errorf("%s does not exist", username)
-	}
+	username = user.Username
 
 	specimen := new(Specimen)
 	_ = json.Unmarshal(checkExistence, specimen)
 
-	collectionBytes, _ := ctx.GetStub().GetState(specimen.Collection)
+	collectionBytes, _ := getState(ctx, collectionObjectType, specimen.Collection)
 	collect := new(Collection)
 	_ = json.Unmarshal(collectionBytes, collect)
 
-	user := new(User)
-	_ = json.Unmarshal(checkUser, user)
-
 	role, ok := user.Membership[specimen.Collection]
 
 	if !ok {
@@ -843,6 +668,8 @@ func (s *SmartContract) Override(ctx contractapi.TransactionContextInterface, gu
 		return fmt.Errorf("%s has role %s but role %s is required to update and override primary info", username, role, collect.PrimaryUpdate)
 	}
 
+	oldSpecimen := *specimen
+
 	if condition != "" {
 		specimen.Condition = condition + "\n"
 	}
@@ -870,8 +697,7 @@ func (s *SmartContract) Override(ctx contractapi.TransactionContextInterface, gu
 	}
 
 	attributionString := fmt.Sprintf("Overrode condition, loan, grant, and/or notes history for specimen with guid %s", guid)
-	attributionBytes := []byte(attributionString)
-	err = ctx.GetStub().PutState(username+"|attribution", attributionBytes)
+	err = attribute(ctx, username, "Override", specimenObjectType, guid, guid, attributionString)
 
 	if err != nil {
 		return fmt.Errorf("Failed to put to world state. %s", err.Error())
@@ -879,11 +705,20 @@ func (s *SmartContract) Override(ctx contractapi.TransactionContextInterface, gu
 
 	specimenBytes, _ := json.Marshal(specimen)
 
-	return ctx.GetStub().PutState(guid, specimenBytes)
+	err = putState(ctx, specimenObjectType, guid, specimenBytes)
+
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, ChangeEvent{Action: "Override", ObjectType: specimenObjectType, ID: guid, Actor: username, ChangedFields: changedFields(&oldSpecimen, specimen)})
 }
 
+// RegisterLoan appends free text about a loan to a specimen's loans list.
+//
+// Deprecated: use OpenLoan, which records the loan as a Loan object.
 func (s *SmartContract) RegisterLoan(ctx contractapi.TransactionContextInterface, guid string, username string, description string, loanee string, date string) error {
-	checkExistence, err := ctx.GetStub().GetState(guid)
+	checkExistence, err := getState(ctx, specimenObjectType, guid)
 
 	if err != nil {
 		return fmt.Errorf("Failed to read from world state. %s", err.Error())
@@ -893,26 +728,21 @@ func (s *SmartContract) RegisterLoan(ctx contractapi.TransactionContextInterface
 		return fmt.Errorf("%s does not exists", guid)
 	}
 
-	checkUser, err := ctx.GetStub().GetState(username)
+	user, err := getUser(ctx, username)
 
 	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
+		return err
 	}
 
-	if checkUser == nil {
-		return fmt.Errorf("%s does not exist", username)
-	}
+	username = user.Username
 
 	specimen := new(Specimen)
 	_ = json.Unmarshal(checkExistence, specimen)
 
-	collectionBytes, _ := ctx.GetStub().GetState(specimen.Collection)
+	collectionBytes, _ := getState(ctx, collectionObjectType, specimen.Collection)
 	collect := new(Collection)
 	_ = json.Unmarshal(collectionBytes, collect)
 
-	user := new(User)
-	_ = json.Unmarshal(checkUser, user)
-
 	role, ok := user.Membership[specimen.Collection]
 
 	if !ok {
@@ -926,8 +756,7 @@ func (s *SmartContract) RegisterLoan(ctx contractapi.TransactionContextInterface
 	specimen.Loans = specimen.Loans + "Loaned: " + description + " to " + loanee + " on " + date + "\n"
 
 	attributionString := fmt.Sprintf("Registered loan for specimen with GUID %s", guid)
-	attributionBytes := []byte(attributionString)
-	err = ctx.GetStub().PutState(username+"|attribution", attributionBytes)
+	err = attribute(ctx, username, "RegisterLoan", specimenObjectType, guid, guid, attributionString)
 
 	if err != nil {
 		return fmt.Errorf("Failed to put to world state. %s", err.Error())
@@ -935,11 +764,20 @@ func (s *SmartContract) RegisterLoan(ctx contractapi.TransactionContextInterface
 
 	specimenBytes, _ := json.Marshal(specimen)
 
-	return ctx.GetStub().PutState(guid, specimenBytes)
+	err = putState(ctx, specimenObjectType, guid, specimenBytes)
+
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, ChangeEvent{Action: "RegisterLoan", ObjectType: specimenObjectType, ID: guid, Actor: username, ChangedFields: []string{"loans"}})
 }
 
+// ReturnLoan appends free text about a loan's return to a specimen's loans list.
+//
+// Deprecated: use ReturnLoanParts and CloseLoan on the Loan object opened by OpenLoan.
 func (s *SmartContract) ReturnLoan(ctx contractapi.TransactionContextInterface, guid string, username string, description string, loanee string, date string) error {
-	checkExistence, err := ctx.GetStub().GetState(guid)
+	checkExistence, err := getState(ctx, specimenObjectType, guid)
 
 	if err != nil {
 		return fmt.Errorf("Failed to read from world state. %s", err.Error())
@@ -949,26 +787,21 @@ func (s *SmartContract) ReturnLoan(ctx contractapi.TransactionContextInterface,
 		return fmt.Errorf("%s does not exists", guid)
 	}
 
-	checkUser, err := ctx.GetStub().GetState(username)
+	user, err := getUser(ctx, username)
 
 	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
+		return err
 	}
 
-	if checkUser == nil {
-		return fmt.Errorf("%s does not exist", username)
-	}
+	username = user.Username
 
 	specimen := new(Specimen)
 	_ = json.Unmarshal(checkExistence, specimen)
 
-	collectionBytes, _ := ctx.GetStub().GetState(specimen.Collection)
+	collectionBytes, _ := getState(ctx, collectionObjectType, specimen.Collection)
 	collect := new(Collection)
 	_ = json.Unmarshal(collectionBytes, collect)
 
-	user := new(User)
-	_ = json.Unmarshal(checkUser, user)
-
 	role, ok := user.Membership[specimen.Collection]
 
 	if !ok {
@@ -984,18 +817,23 @@ func (s *SmartContract) ReturnLoan(ctx contractapi.TransactionContextInterface,
 	specimenBytes, _ := json.Marshal(specimen)
 
 	attributionString := fmt.Sprintf("Returned loan for specimen with GUID %s", guid)
-	attributionBytes := []byte(attributionString)
-	err = ctx.GetStub().PutState(username+"|attribution", attributionBytes)
+	err = attribute(ctx, username, "ReturnLoan", specimenObjectType, guid, guid, attributionString)
 
 	if err != nil {
 		return fmt.Errorf("Failed to put to world state. %s", err.Error())
 	}
 
-	return ctx.GetStub().PutState(guid, specimenBytes)
+	err = putState(ctx, specimenObjectType, guid, specimenBytes)
+
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, ChangeEvent{Action: "ReturnLoan", ObjectType: specimenObjectType, ID: guid, Actor: username, ChangedFields: []string{"loans"}})
 }
 
 func (s *SmartContract) RegisterGrant(ctx contractapi.TransactionContextInterface, guid string, username string, description string, grantee string, date string) error {
-	checkExistence, err := ctx.GetStub().GetState(guid)
+	checkExistence, err := getState(ctx, specimenObjectType, guid)
 
 	if err != nil {
 		return fmt.Errorf("Failed to read from world state. %s", err.Error())
@@ -1005,26 +843,21 @@ func (s *SmartContract) RegisterGrant(ctx contractapi.TransactionContextInterfac
 		return fmt.Errorf("%s does not exists", guid)
 	}
 
-	checkUser, err := ctx.GetStub().GetState(username)
+	user, err := getUser(ctx, username)
 
 	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
+		return err
 	}
 
-	if checkUser == nil {
-		return fmt.Errorf("%s does not exist", username)
-	}
+	username = user.Username
 
 	specimen := new(Specimen)
 	_ = json.Unmarshal(checkExistence, specimen)
 
-	collectionBytes, _ := ctx.GetStub().GetState(specimen.Collection)
+	collectionBytes, _ := getState(ctx, collectionObjectType, specimen.Collection)
 	collect := new(Collection)
 	_ = json.Unmarshal(collectionBytes, collect)
 
-	user := new(User)
-	_ = json.Unmarshal(checkUser, user)
-
 	role, ok := user.Membership[specimen.Collection]
 
 	if !ok {
@@ -1038,8 +871,7 @@ func (s *SmartContract) RegisterGrant(ctx contractapi.TransactionContextInterfac
 	specimen.Grants = specimen.Grants + "Granted: " + description + " to " + grantee + " on " + date + "\n"
 
 	attributionString := fmt.Sprintf("Registered grant for specimen with GUID %s", guid)
-	attributionBytes := []byte(attributionString)
-	err = ctx.GetStub().PutState(username+"|attribution", attributionBytes)
+	err = attribute(ctx, username, "RegisterGrant", specimenObjectType, guid, guid, attributionString)
 
 	if err != nil {
 		return fmt.Errorf("Failed to put to world state. %s", err.Error())
@@ -1047,11 +879,17 @@ func (s *SmartContract) RegisterGrant(ctx contractapi.TransactionContextInterfac
 
 	specimenBytes, _ := json.Marshal(specimen)
 
-	return ctx.GetStub().PutState(guid, specimenBytes)
+	err = putState(ctx, specimenObjectType, guid, specimenBytes)
+
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, ChangeEvent{Action: "RegisterGrant", ObjectType: specimenObjectType, ID: guid, Actor: username, ChangedFields: []string{"grants"}})
 }
 
 func (s *SmartContract) Query(ctx contractapi.TransactionContextInterface, guid string, username string) (*Specimen, error) {
-	specimenBytes, err := ctx.GetStub().GetState(guid)
+	specimenBytes, err := getState(ctx, specimenObjectType, guid)
 
 	if err != nil {
 		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
@@ -1061,27 +899,22 @@ func (s *SmartContract) Query(ctx contractapi.TransactionContextInterface, guid
 		return nil, fmt.Errorf("%s does not exist", guid)
 	}
 
-	checkUser, err := ctx.GetStub().GetState(username)
+	user, err := getUser(ctx, username)
 
 	if err != nil {
-		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+		return nil, err
 	}
 
-	if checkUser == nil {
-		return nil, fmt.Errorf("%s does not exist", username)
-	}
+	username = user.Username
 
 	specimen := new(Specimen)
 	_ = json.Unmarshal(specimenBytes, specimen)
 
-	collectionBytes, err := ctx.GetStub().GetState(specimen.Collection)
+	collectionBytes, err := getState(ctx, collectionObjectType, specimen.Collection)
 
 	collection := new(Collection)
 	_ = json.Unmarshal(collectionBytes, collection)
 
-	user := new(User)
-	_ = json.Unmarshal(checkUser, user)
-
 	role, ok := user.Membership[specimen.Collection]
 
 	if !ok {
@@ -1092,27 +925,66 @@ func (s *SmartContract) Query(ctx contractapi.TransactionContextInterface, guid
 		return nil, fmt.Errorf("%s has role %s but role %s is required to query specimens", username, role, collection.Query)
 	}
 
-	return specimen, nil
+	return redact(collection, role, specimen), nil
 }
 
-func (s *SmartContract) GetHistory(ctx contractapi.TransactionContextInterface, guid string) (string, error) {
-	recordIterator, err := ctx.GetStub().GetHistoryForKey(guid)
+// GetEntityHistory returns the ledger history of a user, collection or attribution. Specimen history is access controlled and returned by GetHistory.
+// Collection history requires the user to be able to query the collection, and attribution history leaves out the transactions of contributions the user could not see through QueryContributions.
+func (s *SmartContract) GetEntityHistory(ctx contractapi.TransactionContextInterface, objectType string, id string, username string) (string, error) {
+	if objectType == specimenObjectType {
+		return "", fmt.Errorf("Specimen history must be fetched with GetHistory, which checks the user's permission to query the specimen")
+	}
+
+	if objectType != userObjectType && objectType != collectionObjectType && objectType != attributionObjectType {
+		return "", fmt.Errorf("%s is not a valid object type. Valid object types are %s, %s, and %s", objectType, userObjectType, collectionObjectType, attributionObjectType)
+	}
+
+	_, err := getUser(ctx, username)
 
 	if err != nil {
-		return "", fmt.Errorf("Failed to read from world state. %s", err.Error())
+		return "", err
 	}
 
-	defer recordIterator.Close()
+	//Attribution strings are written by the transactions which record contributions, so a transaction is visible if any of its contributions are
+	var visibleTxIDs map[string]bool
 
-	specimenBytes, err := ctx.GetStub().GetState(guid)
+	switch objectType {
+	case collectionObjectType:
+		err = collectionQueryAccess(ctx, id, username)
 
-	specimen := new(Specimen)
-	_ = json.Unmarshal(specimenBytes, specimen)
+		if err != nil {
+			return "", err
+		}
+	case attributionObjectType:
+		visible, err := visibleContributions(ctx, id, username)
+
+		if err != nil {
+			return "", err
+		}
+
+		visibleTxIDs = make(map[string]bool)
+
+		for _, contribution := range visible {
+			visibleTxIDs[contribution.TxID] = true
+		}
+	}
+
+	key, err := stateKey(ctx, objectType, id)
+
+	if err != nil {
+		return "", err
+	}
+
+	recordIterator, err := ctx.GetStub().GetHistoryForKey(key)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	defer recordIterator.Close()
 
-	var buffer bytes.Buffer
-	buffer.WriteString("[")
+	entries := []EntityHistoryEntry{}
 
-	isArrayMemberWritten := false
 	for recordIterator.HasNext() {
 		response, err := recordIterator.Next()
 
@@ -1120,59 +992,39 @@ func (s *SmartContract) GetHistory(ctx contractapi.TransactionContextInterface,
 			return "", fmt.Errorf("Error. %s", err.Error())
 		}
 
-		if isArrayMemberWritten {
-			buffer.WriteString(",")
+		if visibleTxIDs != nil && !visibleTxIDs[response.TxId] {
+			continue
 		}
 
-		buffer.WriteString("{\"TxId\":")
-		buffer.WriteString("\"")
-		buffer.WriteString(response.TxId)
-		buffer.WriteString("\"")
+		entry := EntityHistoryEntry{
+			TxID:      response.TxId,
+			Timestamp: time.Unix(response.Timestamp.Seconds, int64(response.Timestamp.Nanos)).UTC().Format(time.RFC3339Nano),
+			IsDelete:  response.IsDelete,
+		}
 
-		buffer.WriteString(", \"Value\":")
-		// if it was a delete operation on given key, then we need to set the corresponding value null.
+		//Users and collections are stored as JSON, but attributions are plain strings
 		if response.IsDelete {
-			buffer.WriteString("null")
+			entry.Value = json.RawMessage("null")
+		} else if objectType == attributionObjectType {
+			entry.Value, _ = json.Marshal(string(response.Value))
 		} else {
-			//check if the specimen history is hidden due to vandalism
-			hidden := false
-
-			for _, txid := range specimen.VandalizedTransactions {
-				if txid == response.TxId {
-					hidden = true
-					break
-				}
-			}
-
-			if hidden {
-				buffer.WriteString("\"Record hidden due to vandalism\"")
-			} else {
-				buffer.WriteString(string(response.Value))
-			}
-
+			entry.Value = json.RawMessage(response.Value)
 		}
 
-		buffer.WriteString(", \"Timestamp\":")
-		buffer.WriteString("\"")
-		buffer.WriteString(time.Unix(response.Timestamp.Seconds, int64(response.Timestamp.Nanos)).String())
-		buffer.WriteString("\"")
+		entries = append(entries, entry)
+	}
 
-		buffer.WriteString(", \"IsDelete\":")
-		buffer.WriteString("\"")
-		buffer.WriteString(strconv.FormatBool(response.IsDelete))
-		buffer.WriteString("\"")
+	entriesBytes, err := json.Marshal(entries)
 
-		buffer.WriteString("}")
-		isArrayMemberWritten = true
+	if err != nil {
+		return "", fmt.Errorf("Failed to encode history. %s", err.Error())
 	}
 
-	buffer.WriteString("]")
-
-	return buffer.String(), nil
+	return string(entriesBytes), nil
 }
 
 func (s *SmartContract) Hide(ctx contractapi.TransactionContextInterface, guid string, username string, txid string) error {
-	specimenBytes, err := ctx.GetStub().GetState(guid)
+	specimenBytes, err := getState(ctx, specimenObjectType, guid)
 
 	if err != nil {
 		return fmt.Errorf("Failed to read from world state. %s", err.Error())
@@ -1182,27 +1034,22 @@ func (s *SmartContract) Hide(ctx contractapi.TransactionContextInterface, guid s
 		return fmt.Errorf("%s does not exist", guid)
 	}
 
-	checkUser, err := ctx.GetStub().GetState(username)
+	user, err := getUser(ctx, username)
 
 	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
+		return err
 	}
 
-	if checkUser == nil {
-		return fmt.Errorf("%s does not exist", username)
-	}
+	username = user.Username
 
 	specimen := new(Specimen)
 	_ = json.Unmarshal(specimenBytes, specimen)
 
-	collectionBytes, err := ctx.GetStub().GetState(specimen.Collection)
+	collectionBytes, err := getState(ctx, collectionObjectType, specimen.Collection)
 
 	collection := new(Collection)
 	_ = json.Unmarshal(collectionBytes, collection)
 
-	user := new(User)
-	_ = json.Unmarshal(checkUser, user)
-
 	role, ok := user.Membership[specimen.Collection]
 
 	if !ok {
@@ -1215,13 +1062,26 @@ func (s *SmartContract) Hide(ctx contractapi.TransactionContextInterface, guid s
 
 	specimen.VandalizedTransactions = append(specimen.VandalizedTransactions, txid)
 
+	attributionString := fmt.Sprintf("Hid transaction %s of specimen with GUID %s", txid, guid)
+	err = attribute(ctx, username, "Hide", specimenObjectType, guid, guid, attributionString)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
 	specimenBytes, _ = json.Marshal(specimen)
 
-	return ctx.GetStub().PutState(guid, specimenBytes)
+	err = putState(ctx, specimenObjectType, guid, specimenBytes)
+
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, ChangeEvent{Action: "Hide", ObjectType: specimenObjectType, ID: guid, Actor: username, ChangedFields: []string{"vandalizedTransactions"}})
 }
 
 func (s *SmartContract) Unhide(ctx contractapi.TransactionContextInterface, guid string, username string, txid string) error {
-	specimenBytes, err := ctx.GetStub().GetState(guid)
+	specimenBytes, err := getState(ctx, specimenObjectType, guid)
 
 	if err != nil {
 		return fmt.Errorf("Failed to read from world state. %s", err.Error())
@@ -1231,27 +1091,22 @@ func (s *SmartContract) Unhide(ctx contractapi.TransactionContextInterface, guid
 		return fmt.Errorf("%s does not exist", guid)
 	}
 
-	checkUser, err := ctx.GetStub().GetState(username)
+	user, err := getUser(ctx, username)
 
 	if err != nil {
-		return fmt.Errorf("Failed to read from world state. %s", err.Error())
+		return err
 	}
 
-	if checkUser == nil {
-		return fmt.Errorf("%s does not exist", username)
-	}
+	username = user.Username
 
 	specimen := new(Specimen)
 	_ = json.Unmarshal(specimenBytes, specimen)
 
-	collectionBytes, err := ctx.GetStub().GetState(specimen.Collection)
+	collectionBytes, err := getState(ctx, collectionObjectType, specimen.Collection)
 
 	collection := new(Collection)
 	_ = json.Unmarshal(collectionBytes, collection)
 
-	user := new(User)
-	_ = json.Unmarshal(checkUser, user)
-
 	role, ok := user.Membership[specimen.Collection]
 
 	if !ok {
@@ -1268,187 +1123,112 @@ func (s *SmartContract) Unhide(ctx contractapi.TransactionContextInterface, guid
 		}
 	}
 
-	specimenBytes, _ = json.Marshal(specimen)
-
-	return ctx.GetStub().PutState(guid, specimenBytes)
-}
-
-func (s *SmartContract) QueryAllSpecimens(ctx contractapi.TransactionContextInterface) ([]QueryResult, error) {
-	recordIterator, err := ctx.GetStub().GetStateByRange("0", "999999999999")
+	attributionString := fmt.Sprintf("Unhid transaction %s of specimen with GUID %s", txid, guid)
+	err = attribute(ctx, username, "Unhide", specimenObjectType, guid, guid, attributionString)
 
 	if err != nil {
-		return nil, fmt.Errorf("Failed to get record iterator. %s", err.Error())
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
 	}
 
-	defer recordIterator.Close()
-
-	results := []QueryResult{}
-
-	for recordIterator.HasNext() {
-		response, err := recordIterator.Next()
-
-		if err != nil {
-			return nil, fmt.Errorf("Error. %s", err.Error())
-		}
-
-		specimen := new(Specimen)
+	specimenBytes, _ = json.Marshal(specimen)
 
-		err = json.Unmarshal(response.Value, specimen)
-		if err == nil {
-			result := QueryResult{response.Key, specimen}
-			results = append(results, result)
-		}
+	err = putState(ctx, specimenObjectType, guid, specimenBytes)
 
+	if err != nil {
+		return err
 	}
 
-	return results, nil
+	return emitEvent(ctx, ChangeEvent{Action: "Unhide", ObjectType: specimenObjectType, ID: guid, Actor: username, ChangedFields: []string{"vandalizedTransactions"}})
 }
 
-func (s *SmartContract) UpdateTaxonClass(ctx contractapi.TransactionContextInterface, collection string, username string, oldTaxon string, newTaxon string) (int, error) {
-	checkUser, err := ctx.GetStub().GetState(username)
+// QueryAllSpecimens returns every specimen of the collections the user linked to the submitting identity may query, or which are public
+func (s *SmartContract) QueryAllSpecimens(ctx contractapi.TransactionContextInterface) ([]QueryResult, error) {
+	permissions, err := callerPermissions(ctx)
 
 	if err != nil {
-		return 0, fmt.Errorf("Failed to read from world state. %s", err.Error())
-	}
-
-	if checkUser == nil {
-		return 0, fmt.Errorf("%s does not exist", username)
+		return nil, err
 	}
 
-	checkCollection, err := ctx.GetStub().GetState(collection)
+	recordIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(specimenObjectType, []string{})
 
 	if err != nil {
-		return 0, fmt.Errorf("Failed to read from world state. %s", err.Error())
-	}
-	if checkCollection == nil {
-		return 0, fmt.Errorf("%s does not exists", collection)
+		return nil, fmt.Errorf("Failed to get record iterator. %s", err.Error())
 	}
 
-	collect := new(Collection)
-	_ = json.Unmarshal(checkCollection, collect)
-
-	user := new(User)
-	_ = json.Unmarshal(checkUser, user)
-
-	role, ok := user.Membership[collection]
-
-	if !ok {
-		role = "P"
-	}
+	defer recordIterator.Close()
 
-	if !strings.Contains(collect.TaxonClass, role) {
-		return 0, fmt.Errorf("%s has role %s but role %s is required to update taxon class", username, role, collect.TaxonClass)
-	}
+	return authorizedSpecimens(ctx, recordIterator, permissions)
+}
 
-	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+func (s *SmartContract) UpdateTaxonClass(ctx contractapi.TransactionContextInterface, collection string, username string, oldTaxon string, newTaxon string) (int, error) {
+	reassignment, err := reassignTaxon(ctx, "UpdateTaxonClass", collection, username, oldTaxon, newTaxon, 0)
 
 	if err != nil {
-		return 0, fmt.Errorf("Failed to get results Iterator for all specimens. %s", err.Error())
+		return 0, err
 	}
-	defer resultsIterator.Close()
-
-	specimensChanged := 0
 
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
-
-		if err != nil {
-			return 0, fmt.Errorf("Failed to get specimen. %s", err.Error())
-		}
-
-		specimen := new(Specimen)
-		_ = json.Unmarshal(queryResponse.Value, specimen)
-
-		if specimen.Collection == collection && specimen.Taxon == oldTaxon {
-			specimen.Taxon = newTaxon
-
-			specimenBytes, _ := json.Marshal(specimen)
-
-			err = ctx.GetStub().PutState(queryResponse.Key, specimenBytes)
-
-			if err != nil {
-				return 0, fmt.Errorf("Failed to put to world state. %s", err.Error())
-			}
-
-			specimensChanged += 1
-
-		}
-	}
+	return len(reassignment.AffectedGuids), nil
+}
 
-	attributionString := fmt.Sprintf("Updated all %s taxons to %s in collection %s", oldTaxon, newTaxon, collection)
-	attributionBytes := []byte(attributionString)
-	err = ctx.GetStub().PutState(username+"|attribution", attributionBytes)
+// CouchQuery returns the specimens matching a rich query in the collections the user linked to the submitting identity may query
+func (s *SmartContract) CouchQuery(ctx contractapi.TransactionContextInterface, queryString string) ([]Specimen, error) {
+	permissions, err := callerPermissions(ctx)
 
 	if err != nil {
-		return 0, fmt.Errorf("Failed to put to world state. %s", err.Error())
+		return nil, err
 	}
 
-	return specimensChanged, nil
-
-}
-
-func (s *SmartContract) CouchQuery(ctx contractapi.TransactionContextInterface, queryString string) ([]Specimen, error) {
 	recordIterator, err := ctx.GetStub().GetQueryResult(queryString)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get record iterator from query string. %s", err.Error())
 	}
 	defer recordIterator.Close()
 
-	results := []Specimen{}
-
-	for recordIterator.HasNext() {
-		record, err := recordIterator.Next()
-		if err != nil {
-			return nil, fmt.Errorf("Failed to get record from record iterator. %s", err.Error())
-		}
-
-		specimen := new(Specimen)
-
-		err = json.Unmarshal(record.Value, specimen)
+	specimens, err := authorizedSpecimens(ctx, recordIterator, permissions)
 
-		if err != nil {
-			return nil, fmt.Errorf("Failed to unmarshal specimen. %s", err.Error())
-		}
+	if err != nil {
+		return nil, err
+	}
 
-		results = append(results, *specimen)
+	results := []Specimen{}
 
+	for _, specimen := range specimens {
+		results = append(results, *specimen.Record)
 	}
 
 	return results, nil
 }
 
+// CouchQueryPendingTransactions returns the pending transactions of the specimens matching a rich query in the collections the user linked to the submitting identity may query
 func (s *SmartContract) CouchQueryPendingTransactions(ctx contractapi.TransactionContextInterface, queryString string) ([][]PendingTransaction, error) {
+	permissions, err := callerPermissions(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
 	recordIterator, err := ctx.GetStub().GetQueryResult(queryString)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to get record iterator from query string. %s", err.Error())
 	}
 	defer recordIterator.Close()
 
-	results := [][]PendingTransaction{}
-
-	for recordIterator.HasNext() {
-		record, err := recordIterator.Next()
-		if err != nil {
-			return nil, fmt.Errorf("Failed to get record from record iterator. %s", err.Error())
-		}
+	specimens, err := authorizedSpecimens(ctx, recordIterator, permissions)
 
-		pendingTransactionsBytes, err := ctx.GetStub().GetState("pending" + record.Key)
+	if err != nil {
+		return nil, err
+	}
 
-		if err != nil {
-			return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
-		}
+	results := [][]PendingTransaction{}
 
-		pendingTransactions := []PendingTransaction{}
+	for _, specimen := range specimens {
+		pendingTransactions, err := getPendingTransactions(ctx, specimen.Guid)
 
-		if pendingTransactionsBytes != nil {
-			err = json.Unmarshal(pendingTransactionsBytes, &pendingTransactions)
-			if err != nil {
-				return nil, fmt.Errorf("Failed to unmarshal list of pending transactions from bytes. %s", err.Error())
-			}
+		if err != nil {
+			return nil, err
 		}
 
 		results = append(results, pendingTransactions)
-
 	}
 
 	return results, nil
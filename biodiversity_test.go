@@ -0,0 +1,414 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Transactions are stamped one step (a minute unless a test shortens it) apart from this time so that tests do not depend on the clock
+var testEpoch = time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// testStub adds what the mock stub lacks to the chaincode's view of the ledger: transient data, key history, open ended range scans and recorded events.
+// Like a peer, it commits the writes of a transaction only once the transaction succeeds, so a transaction does not read its own writes.
+type testStub struct {
+	*shimtest.MockStub
+	args      [][]byte
+	transient map[string][]byte
+	history   map[string][]*queryresult.KeyModification
+	events    []ChangeEvent
+	writes    []*queryresult.KeyModification
+	keys      []string
+}
+
+func (s *testStub) GetArgs() [][]byte {
+	return s.args
+}
+
+func (s *testStub) GetStringArgs() []string {
+	args := []string{}
+	for _, arg := range s.args {
+		args = append(args, string(arg))
+	}
+	return args
+}
+
+func (s *testStub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	return args[0], args[1:]
+}
+
+func (s *testStub) GetTransient() (map[string][]byte, error) {
+	return s.transient, nil
+}
+
+func (s *testStub) PutState(key string, value []byte) error {
+	s.writes = append(s.writes, &queryresult.KeyModification{TxId: s.TxID, Value: value, Timestamp: s.TxTimestamp})
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+func (s *testStub) DelState(key string) error {
+	s.writes = append(s.writes, &queryresult.KeyModification{TxId: s.TxID, Timestamp: s.TxTimestamp, IsDelete: true})
+	s.keys = append(s.keys, key)
+	return nil
+}
+
+// commit applies the writes of a successful transaction to the world state and the history of their keys
+func (s *testStub) commit() error {
+	for i, write := range s.writes {
+		var err error
+
+		if write.IsDelete {
+			err = s.MockStub.DelState(s.keys[i])
+		} else {
+			err = s.MockStub.PutState(s.keys[i], write.Value)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		s.history[s.keys[i]] = append(s.history[s.keys[i]], write)
+	}
+
+	return nil
+}
+
+// GetHistoryForKey returns the modifications of a key newest first, as a peer does
+func (s *testStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	modifications := []*queryresult.KeyModification{}
+	for i := len(s.history[key]) - 1; i >= 0; i-- {
+		modifications = append(modifications, s.history[key][i])
+	}
+	return &historyIterator{modifications, 0}, nil
+}
+
+// GetStateByRange treats a blank end key as the end of the key space, which the mock stub only does when the start key is blank too
+func (s *testStub) GetStateByRange(startKey string, endKey string) (shim.StateQueryIteratorInterface, error) {
+	if startKey != "" && endKey == "" {
+		endKey = string(utf8.MaxRune)
+	}
+	return s.MockStub.GetStateByRange(startKey, endKey)
+}
+
+// GetQueryResult matches every record, since the mock stub cannot run rich queries. Callers still see only the specimens among them.
+func (s *testStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	records := []*queryresult.KV{}
+	for element := s.Keys.Front(); element != nil; element = element.Next() {
+		key := element.Value.(string)
+		records = append(records, &queryresult.KV{Key: key, Value: s.State[key]})
+	}
+	return &stateIterator{records, 0}, nil
+}
+
+func (s *testStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	recordIterator, err := s.MockStub.GetStateByPartialCompositeKey(objectType, keys)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return page(recordIterator, pageSize, bookmark)
+}
+
+func (s *testStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	recordIterator, err := s.GetQueryResult(query)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return page(recordIterator, pageSize, bookmark)
+}
+
+// page returns up to pageSize records of a query from the bookmark on. The bookmark is the smallest key of the next page.
+func page(recordIterator shim.StateQueryIteratorInterface, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	records := []*queryresult.KV{}
+	next := ""
+
+	for recordIterator.HasNext() {
+		record, err := recordIterator.Next()
+
+		if err != nil {
+			return nil, nil, err
+		}
+		if record.Key < bookmark {
+			continue
+		}
+		if int32(len(records)) == pageSize {
+			next = record.Key
+			break
+		}
+
+		records = append(records, record)
+	}
+
+	//Past the last page the bookmark lies beyond every key
+	if next == "" && len(records) > 0 {
+		next = records[len(records)-1].Key + "\x00"
+	}
+
+	return &stateIterator{records, 0}, &peer.QueryResponseMetadata{FetchedRecordsCount: int32(len(records)), Bookmark: next}, nil
+}
+
+func (s *testStub) SetEvent(name string, payload []byte) error {
+	event := ChangeEvent{}
+	_ = json.Unmarshal(payload, &event)
+	s.events = append(s.events, event)
+	return nil
+}
+
+type historyIterator struct {
+	modifications []*queryresult.KeyModification
+	next          int
+}
+
+func (it *historyIterator) HasNext() bool {
+	return it.next < len(it.modifications)
+}
+
+func (it *historyIterator) Close() error {
+	return nil
+}
+
+func (it *historyIterator) Next() (*queryresult.KeyModification, error) {
+	it.next++
+	return it.modifications[it.next-1], nil
+}
+
+type stateIterator struct {
+	records []*queryresult.KV
+	next    int
+}
+
+func (it *stateIterator) HasNext() bool {
+	return it.next < len(it.records)
+}
+
+func (it *stateIterator) Close() error {
+	return nil
+}
+
+func (it *stateIterator) Next() (*queryresult.KV, error) {
+	it.next++
+	return it.records[it.next-1], nil
+}
+
+// newCreator returns a serialized identity with a self signed certificate for the common name, carrying the enrollment attributes
+func newCreator(t *testing.T, commonName string, attributes map[string]string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"Org1"}},
+		NotBefore:    testEpoch.AddDate(-1, 0, 0),
+		NotAfter:     testEpoch.AddDate(10, 0, 0),
+	}
+
+	if attributes != nil {
+		attributeBytes, _ := json.Marshal(map[string]interface{}{"attrs": attributes})
+		//Fabric CA stores enrollment attributes in this certificate extension
+		template.ExtraExtensions = []pkix.Extension{{Id: asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 1}, Value: attributeBytes}}
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identity := &msp.SerializedIdentity{Mspid: "Org1MSP", IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certBytes})}
+	identityBytes, _ := proto.Marshal(identity)
+	return identityBytes
+}
+
+// contractHarness submits transactions to the contract, seeded by Init, as a shared application identity linked to no user
+type contractHarness struct {
+	t     *testing.T
+	cc    *contractapi.ContractChaincode
+	stub  *testStub
+	count int
+	//Id of the last transaction submitted
+	tx string
+	//Timestamp of the last transaction submitted, and the time between transactions
+	clock time.Time
+	step  time.Duration
+}
+
+// Building the chaincode's transaction metadata is slow, and the chaincode keeps no state of its own, so every test shares one
+var testChaincode struct {
+	once sync.Once
+	cc   *contractapi.ContractChaincode
+	err  error
+}
+
+func newContractHarness(t *testing.T) *contractHarness {
+	testChaincode.once.Do(func() {
+		testChaincode.cc, testChaincode.err = contractapi.NewChaincode(new(SmartContract))
+	})
+
+	cc, err := testChaincode.cc, testChaincode.err
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stub := &testStub{MockStub: shimtest.NewMockStub("biodiversity", cc), history: make(map[string][]*queryresult.KeyModification)}
+	h := &contractHarness{t: t, cc: cc, stub: stub, clock: testEpoch, step: time.Minute}
+	h.as("app", nil)
+	h.ok("Init")
+	return h
+}
+
+// as submits the following transactions with a new identity
+func (h *contractHarness) as(commonName string, attributes map[string]string) {
+	h.stub.Creator = newCreator(h.t, commonName, attributes)
+}
+
+// asAdmin submits the following transactions with a new identity carrying the admin attribute
+func (h *contractHarness) asAdmin() {
+	h.as("admin", map[string]string{adminAttribute: "true"})
+}
+
+// now is the timestamp the next transaction will carry
+func (h *contractHarness) now() time.Time {
+	return h.clock.Add(h.step)
+}
+
+func (h *contractHarness) invoke(transient map[string][]byte, function string, args ...string) (string, string) {
+	h.count++
+	h.tx = fmt.Sprintf("tx%03d", h.count)
+	h.clock = h.now()
+
+	h.stub.args = [][]byte{[]byte(function)}
+	for _, arg := range args {
+		h.stub.args = append(h.stub.args, []byte(arg))
+	}
+	h.stub.transient = transient
+	h.stub.writes, h.stub.keys = nil, nil
+	events := len(h.stub.events)
+
+	h.stub.MockTransactionStart(h.tx)
+	h.stub.TxTimestamp, _ = ptypes.TimestampProto(h.clock)
+	response := h.cc.Invoke(h.stub)
+
+	if response.Message == "" {
+		if err := h.stub.commit(); err != nil {
+			h.t.Fatal(err)
+		}
+	} else {
+		h.stub.events = h.stub.events[:events]
+	}
+
+	h.stub.MockTransactionEnd(h.tx)
+
+	return string(response.Payload), response.Message
+}
+
+// ok submits a transaction which must succeed and returns its payload
+func (h *contractHarness) ok(function string, args ...string) string {
+	h.t.Helper()
+	payload, message := h.invoke(nil, function, args...)
+
+	if message != "" {
+		h.t.Fatalf("%s failed: %s", function, message)
+	}
+
+	return payload
+}
+
+// okTransient is ok with transient data
+func (h *contractHarness) okTransient(transient map[string][]byte, function string, args ...string) string {
+	h.t.Helper()
+	payload, message := h.invoke(transient, function, args...)
+
+	if message != "" {
+		h.t.Fatalf("%s failed: %s", function, message)
+	}
+
+	return payload
+}
+
+// fail submits a transaction which must fail and returns its error message
+func (h *contractHarness) fail(function string, args ...string) string {
+	h.t.Helper()
+	_, message := h.invoke(nil, function, args...)
+
+	if message == "" {
+		h.t.Fatalf("%s unexpectedly succeeded", function)
+	}
+
+	return message
+}
+
+// okInto submits a transaction which must succeed and unmarshals its payload into result
+func (h *contractHarness) okInto(result interface{}, function string, args ...string) {
+	h.t.Helper()
+	payload := h.ok(function, args...)
+
+	if err := json.Unmarshal([]byte(payload), result); err != nil {
+		h.t.Fatalf("%s returned %s. %s", function, payload, err.Error())
+	}
+}
+
+// lastEvent returns the event emitted by the last transaction
+func (h *contractHarness) lastEvent() ChangeEvent {
+	h.t.Helper()
+
+	if len(h.stub.events) == 0 {
+		h.t.Fatalf("No event was emitted")
+	}
+
+	return h.stub.events[len(h.stub.events)-1]
+}
+
+func TestInitSeedsSampleData(t *testing.T) {
+	h := newContractHarness(t)
+
+	specimen := Specimen{}
+	h.okInto(&specimen, "Query", "0", "manager")
+
+	if specimen.Collection != "KU Ornithology" {
+		t.Errorf("Sample specimen belongs to %q", specimen.Collection)
+	}
+
+	for _, username := range []string{"manager", "curator", "assistant", "student", "public"} {
+		h.ok("Query", "0", username)
+	}
+}
+
+func TestInitDoesNotResetConfig(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.asAdmin()
+	h.ok("SetIdentityMode", identityModeStrict)
+	h.ok("Init")
+
+	h.as("app", nil)
+	h.fail("Query", "0", "manager")
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// numberHolders returns the specimens other than guid which a catalog or accession number index holds a number for
+func numberHolders(ctx contractapi.TransactionContextInterface, index string, collection string, number string, guid string) ([]string, error) {
+	guids, err := indexedIDs(ctx, index, []string{collection, number})
+
+	if err != nil {
+		return nil, err
+	}
+
+	holders := []string{}
+
+	for _, holder := range guids {
+		if holder != guid {
+			holders = append(holders, holder)
+		}
+	}
+
+	return holders, nil
+}
+
+// queryByNumber returns the specimens of a collection with a catalog or accession number.
+// More than one specimen is only returned for numbers which were shared before uniqueness was enforced.
+func queryByNumber(ctx contractapi.TransactionContextInterface, index string, collection string, number string, username string) ([]QueryResult, error) {
+	permissions, err := collectionQueryPermissions(ctx, collection, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	guids, err := indexedIDs(ctx, index, []string{collection, number})
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := []QueryResult{}
+
+	for _, guid := range guids {
+		specimen, err := getSpecimen(ctx, guid)
+
+		if err != nil {
+			return nil, err
+		}
+
+		specimen, err = permissions.redact(ctx, specimen)
+
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, QueryResult{guid, specimen})
+	}
+
+	return results, nil
+}
+
+func (s *SmartContract) QueryByCatalogNumber(ctx contractapi.TransactionContextInterface, collection string, catalogNumber string, username string) ([]QueryResult, error) {
+	return queryByNumber(ctx, collectionCatalogNumberIndex, collection, catalogNumber, username)
+}
+
+func (s *SmartContract) QueryByAccessionNumber(ctx contractapi.TransactionContextInterface, collection string, accessionNumber string, username string) ([]QueryResult, error) {
+	return queryByNumber(ctx, collectionAccessionNumberIndex, collection, accessionNumber, username)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCatalogAndAccessionNumbersAreUnique(t *testing.T) {
+	h := newContractHarness(t)
+
+	//The sample specimen already has catalog number 32581
+	message := h.fail("CreateSpecimen", `{"guid":"n1","updater":"manager","collection":"KU Ornithology","catalogNumber":"32581"}`)
+
+	if !strings.Contains(message, "catalogNumber") {
+		t.Errorf("Repeated catalog number failed with %s", message)
+	}
+
+	h.ok("CreateSpecimen", `{"guid":"n1","updater":"manager","collection":"KU Ornithology","catalogNumber":"1","accessionNumber":"A1"}`)
+	h.fail("CreateSpecimen", `{"guid":"n2","updater":"manager","collection":"KU Ornithology","catalogNumber":"2","accessionNumber":"A1"}`)
+
+	//A specimen's own numbers do not block changes to its other fields
+	h.ok("PatchSpecimen", `{"guid":"n1","updater":"manager","habitat":"reef"}`)
+
+	//A number is freed when its specimen changes it
+	h.ok("PatchSpecimen", `{"guid":"n1","updater":"manager","catalogNumber":"2"}`)
+	h.ok("CreateSpecimen", `{"guid":"n2","updater":"manager","collection":"KU Ornithology","catalogNumber":"1"}`)
+
+	result := BatchResult{}
+	h.okInto(&result, "CreateSpecimens", `[{"guid":"n3","updater":"manager","collection":"KU Ornithology","catalogNumber":"9"},{"guid":"n4","updater":"manager","collection":"KU Ornithology","catalogNumber":"9"}]`, "true")
+
+	if result.Created != 1 || result.Rows[1].Status != batchRowFailed {
+		t.Errorf("Batch repeating a catalog number gave %+v", result)
+	}
+}
+
+func TestQueryByNumber(t *testing.T) {
+	h := newContractHarness(t)
+	h.ok("CreateSpecimen", `{"guid":"n1","updater":"manager","collection":"KU Ornithology","catalogNumber":"1","accessionNumber":"A1"}`)
+
+	results := []QueryResult{}
+	h.okInto(&results, "QueryByCatalogNumber", "KU Ornithology", "1", "public")
+
+	if guids := resultGuids(results); !equalGuids(guids, "n1") {
+		t.Errorf("Catalog number 1 belongs to %v", guids)
+	}
+
+	h.okInto(&results, "QueryByAccessionNumber", "KU Ornithology", "2002-IC-062", "public")
+
+	if guids := resultGuids(results); !equalGuids(guids, "0") {
+		t.Errorf("Accession number 2002-IC-062 belongs to %v", guids)
+	}
+
+	h.okInto(&results, "QueryByCatalogNumber", "KU Ornithology", "2", "public")
+
+	if len(results) != 0 {
+		t.Errorf("Unused catalog number belongs to %+v", results)
+	}
+
+	h.fail("QueryByCatalogNumber", "KU Ornithology", "1", "nobody")
+}
@@ -0,0 +1,385 @@
+// Command bulkimport converts a CSV catalogue or Darwin Core occurrence file into batches of specimens for the CreateSpecimens transaction,
+// and reports the rows which failed once the batches have been submitted.
+//
+// Usage:
+//
+//	bulkimport -in catalogue.csv -collection 'KU Ornithology' -updater manager -out batches
+//	bulkimport -in dwca.zip -map 'catalogNumber=accessionNumber' -out batches
+//	bulkimport -report batches/batch-0001.result.json batches/batch-0002.result.json
+//
+// Columns are matched to specimen fields by their SpecimenPatch names (e.g. catalogNumber, fieldDate) or by their Darwin Core terms (e.g. recordedBy, eventDate).
+// Each batch is written as a JSON array which is small enough to submit as one transaction. Rows without a guid are given one by CreateSpecimens.
+// Beside each batch, batch-0001.lines records the input line of each of its rows, so that failures are reported by the line to correct in the input.
+package main
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"ku.edu/hyperledger/chaincode/biodiversity/dwca"
+)
+
+// specimenFields are the SpecimenPatch fields a column may fill directly
+var specimenFields = []string{"guid", "updater", "collection", "catalogNumber", "accessionNumber", "catalogDate", "cataloger", "taxon", "determiner", "determineDate", "fieldNumber", "fieldDate", "collector", "location", "latitude", "longitude", "habitat", "preparation", "condition", "conditionDate", "notes", "image"}
+
+type options struct {
+	in         string
+	out        string
+	mapping    string
+	collection string
+	updater    string
+	rows       int
+	bytes      int
+}
+
+// batchRowResult and batchResult mirror the BatchResult returned by CreateSpecimens
+type batchRowResult struct {
+	Row    int    `json:"row"`
+	Guid   string `json:"guid"`
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
+type batchResult struct {
+	Created int              `json:"created"`
+	Failed  int              `json:"failed"`
+	Rows    []batchRowResult `json:"rows"`
+}
+
+func main() {
+	opts := options{}
+	report := flag.Bool("report", false, "report the failed rows of the CreateSpecimens results named as arguments instead of importing")
+	flag.StringVar(&opts.in, "in", "", "CSV file, tab separated Darwin Core occurrence file, or zipped Darwin Core Archive to import")
+	flag.StringVar(&opts.out, "out", "batches", "directory to write batches to")
+	flag.StringVar(&opts.mapping, "map", "", "comma separated column=field pairs overriding how columns are matched to specimen fields")
+	flag.StringVar(&opts.collection, "collection", "", "collection of rows which have none")
+	flag.StringVar(&opts.updater, "updater", "", "updater of rows which have none")
+	flag.IntVar(&opts.rows, "rows", 500, "maximum specimens per batch (CreateSpecimens accepts at most 500)")
+	flag.IntVar(&opts.bytes, "bytes", 512*1024, "maximum size of a batch in bytes")
+	flag.Parse()
+
+	var err error
+
+	if *report {
+		err = reportFailures(os.Stdout, flag.Args())
+	} else {
+		err = importRows(opts)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// readTable returns the header and rows of the input, reading occurrence.txt from a zipped archive
+func readTable(in string) ([]string, [][]string, error) {
+	var input io.Reader
+	comma := '\t'
+
+	switch strings.ToLower(filepath.Ext(in)) {
+	case ".zip":
+		archive, err := zip.OpenReader(in)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		defer archive.Close()
+
+		for _, file := range archive.File {
+			if file.Name == "occurrence.txt" {
+				f, err := file.Open()
+
+				if err != nil {
+					return nil, nil, err
+				}
+
+				defer f.Close()
+				input = f
+			}
+		}
+
+		if input == nil {
+			return nil, nil, fmt.Errorf("%s does not contain occurrence.txt", in)
+		}
+	case ".csv":
+		comma = ','
+		fallthrough
+	default:
+		f, err := os.Open(in)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		defer f.Close()
+		input = f
+	}
+
+	reader := csv.NewReader(input)
+	reader.Comma = comma
+	reader.LazyQuotes = true
+	reader.FieldsPerRecord = -1
+
+	records, err := reader.ReadAll()
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to read %s. %s", in, err.Error())
+	}
+
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("%s is empty", in)
+	}
+
+	return records[0], records[1:], nil
+}
+
+// columnFields matches each column of the header to a specimen field, or "" for a column which is not imported
+func columnFields(header []string, mapping string) ([]string, error) {
+	overrides := make(map[string]string)
+
+	for _, pair := range strings.Split(mapping, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s is not a column=field pair", pair)
+		}
+
+		overrides[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+
+	known := make(map[string]bool)
+	for _, field := range specimenFields {
+		known[field] = true
+	}
+
+	fields := make([]string, len(header))
+
+	for i, column := range header {
+		column = strings.TrimSpace(column)
+
+		if field, ok := overrides[column]; ok {
+			if field != "" && !known[field] {
+				return nil, fmt.Errorf("%s is not a specimen field", field)
+			}
+			fields[i] = field
+		} else if known[column] {
+			fields[i] = column
+		} else if field, ok := dwca.SpecimenFields[dwca.TermName(column)]; ok {
+			fields[i] = field
+		} else {
+			fmt.Fprintf(os.Stderr, "Column %s is not imported\n", column)
+		}
+	}
+
+	return fields, nil
+}
+
+func importRows(opts options) error {
+	if opts.in == "" {
+		return fmt.Errorf("-in is required")
+	}
+
+	header, records, err := readTable(opts.in)
+
+	if err != nil {
+		return err
+	}
+
+	fields, err := columnFields(header, opts.mapping)
+
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(opts.out, 0755)
+
+	if err != nil {
+		return err
+	}
+
+	batch := []json.RawMessage{}
+	//The input line of each row in the batch
+	lines := []int{}
+	batchBytes := 0
+	batches := 0
+	imported := 0
+	skipped := 0
+	minted := 0
+
+	writeBatch := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		batches += 1
+		name := filepath.Join(opts.out, fmt.Sprintf("batch-%04d.json", batches))
+		batchJSON, _ := json.Marshal(batch)
+
+		err := ioutil.WriteFile(name, batchJSON, 0644)
+
+		if err != nil {
+			return err
+		}
+
+		linesJSON, _ := json.Marshal(lines)
+
+		err = ioutil.WriteFile(linesFile(name), linesJSON, 0644)
+
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("%s: %d specimens\n", name, len(batch))
+		batch = []json.RawMessage{}
+		lines = []int{}
+		batchBytes = 0
+		return nil
+	}
+
+	for i, record := range records {
+		//Line numbers count the header, as a spreadsheet would show them
+		line := i + 2
+		specimen := make(map[string]string)
+
+		for j, value := range record {
+			if j < len(fields) && fields[j] != "" && value != "" {
+				specimen[fields[j]] = value
+			}
+		}
+
+		if specimen["collection"] == "" && opts.collection != "" {
+			specimen["collection"] = opts.collection
+		}
+		if specimen["updater"] == "" && opts.updater != "" {
+			specimen["updater"] = opts.updater
+		}
+
+		if len(specimen) == 0 {
+			fmt.Fprintf(os.Stderr, "Line %d skipped: no values\n", line)
+			skipped += 1
+			continue
+		}
+
+		if specimen["guid"] == "" {
+			minted += 1
+		}
+
+		specimenJSON, _ := json.Marshal(specimen)
+
+		if len(batch) >= opts.rows || (len(batch) > 0 && batchBytes+len(specimenJSON)+1 > opts.bytes) {
+			err = writeBatch()
+
+			if err != nil {
+				return err
+			}
+		}
+
+		batch = append(batch, specimenJSON)
+		lines = append(lines, line)
+		batchBytes += len(specimenJSON) + 1
+		imported += 1
+	}
+
+	err = writeBatch()
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%d specimens in %d batches (%d without a guid, which CreateSpecimens will mint), %d rows skipped\n", imported, batches, minted, skipped)
+	return nil
+}
+
+// linesFile names the file recording the input lines of a batch's rows, given the name of the batch or of its saved result
+func linesFile(name string) string {
+	base := strings.TrimSuffix(name, ".json")
+	base = strings.TrimSuffix(base, ".result")
+
+	return base + ".lines"
+}
+
+// readLines returns the input lines of a batch's rows, or nil when the batch was not written with them
+func readLines(resultFile string) ([]int, error) {
+	linesBytes, err := ioutil.ReadFile(linesFile(resultFile))
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []int{}
+
+	err = json.Unmarshal(linesBytes, &lines)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal %s. %s", linesFile(resultFile), err.Error())
+	}
+
+	return lines, nil
+}
+
+// reportFailures prints every failed row of the results CreateSpecimens returned for each batch, by its input line when the batch's lines were saved beside it
+func reportFailures(w io.Writer, resultFiles []string) error {
+	if len(resultFiles) == 0 {
+		return fmt.Errorf("No result files to report")
+	}
+
+	created := 0
+	failed := 0
+
+	for _, name := range resultFiles {
+		resultBytes, err := ioutil.ReadFile(name)
+
+		if err != nil {
+			return err
+		}
+
+		result := batchResult{}
+
+		err = json.Unmarshal(resultBytes, &result)
+
+		if err != nil {
+			return fmt.Errorf("Failed to unmarshal %s. %s", name, err.Error())
+		}
+
+		lines, err := readLines(name)
+
+		if err != nil {
+			return err
+		}
+
+		created += result.Created
+		failed += result.Failed
+
+		for _, row := range result.Rows {
+			if row.Error == "" {
+				continue
+			}
+
+			if row.Row >= 0 && row.Row < len(lines) {
+				fmt.Fprintf(w, "%s line %d (%s): %s\n", name, lines[row.Row], row.Guid, row.Error)
+			} else {
+				fmt.Fprintf(w, "%s row %d (%s): %s\n", name, row.Row, row.Guid, row.Error)
+			}
+		}
+	}
+
+	fmt.Fprintf(w, "%d created, %d failed\n", created, failed)
+	return nil
+}
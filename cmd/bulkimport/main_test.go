@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestColumnFields(t *testing.T) {
+	fields, err := columnFields([]string{"catalogNumber", "recordedBy", "eventDate", "Field Notes", "otherCatalogNumbers"}, "Field Notes=notes, otherCatalogNumbers=")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"catalogNumber", "collector", "fieldDate", "notes", ""}
+
+	for i := range expected {
+		if fields[i] != expected[i] {
+			t.Errorf("Column %d is imported as %q rather than %q", i, fields[i], expected[i])
+		}
+	}
+
+	_, err = columnFields([]string{"catalogNumber"}, "catalogNumber=catalog")
+
+	if err == nil {
+		t.Errorf("A column was mapped to a field which does not exist")
+	}
+
+	_, err = columnFields([]string{"catalogNumber"}, "catalogNumber")
+
+	if err == nil {
+		t.Errorf("A mapping without a field was accepted")
+	}
+}
+
+func TestReportFailuresByInputLine(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bulkimport")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer os.RemoveAll(dir)
+
+	in := filepath.Join(dir, "catalogue.csv")
+	err = ioutil.WriteFile(in, []byte("guid,catalogNumber\na,1\n,\nb,2\nc,3\n"), 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = importRows(options{in: in, out: dir, rows: 2, bytes: 1024})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//Line 3 has no values, so the second batch starts at line 5
+	result := `{"created":0,"failed":1,"rows":[{"row":0,"guid":"c","status":"failed","error":"c already exists"}]}`
+	resultFile := filepath.Join(dir, "batch-0002.result.json")
+	err = ioutil.WriteFile(resultFile, []byte(result), 0644)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var report bytes.Buffer
+	err = reportFailures(&report, []string{resultFile})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(report.String(), "batch-0002.result.json line 5 (c): c already exists") {
+		t.Errorf("Report is %q", report.String())
+	}
+}
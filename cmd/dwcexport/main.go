@@ -0,0 +1,112 @@
+// Command dwcexport packages the occurrences returned by the ExportDarwinCore query into a Darwin Core Archive.
+// ExportDarwinCore returns a collection one page at a time, and the input holds every page one after another.
+//
+// Usage:
+//
+//	dwcexport -in occurrences.json -collection 'KU Ornithology' -title 'KU Ornithology specimens' -out ku-ornithology.zip
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"ku.edu/hyperledger/chaincode/biodiversity/dwca"
+)
+
+func main() {
+	in := flag.String("in", "", "JSON pages of occurrences returned by ExportDarwinCore, one after another (default stdin)")
+	out := flag.String("out", "dwca.zip", "archive to write")
+	dataset := dwca.Dataset{PubDate: time.Now().UTC()}
+	flag.StringVar(&dataset.Title, "title", "", "dataset title")
+	flag.StringVar(&dataset.Institution, "institution", "", "institution publishing the dataset")
+	flag.StringVar(&dataset.Collection, "collection", "", "name of the collection exported")
+	flag.StringVar(&dataset.Contact, "contact", "", "name of the dataset contact")
+	flag.StringVar(&dataset.Email, "email", "", "email address of the dataset contact")
+	flag.StringVar(&dataset.Description, "description", "", "dataset abstract")
+	flag.StringVar(&dataset.License, "license", "", "license the occurrences are published under (e.g. a Creative Commons URL)")
+	flag.Parse()
+
+	err := export(*in, *out, dataset)
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// occurrencePage is the part of a page returned by ExportDarwinCore which is archived
+type occurrencePage struct {
+	Records []dwca.Occurrence `json:"records"`
+}
+
+// readOccurrences reads the occurrences of every page in the input
+func readOccurrences(input io.Reader) ([]dwca.Occurrence, error) {
+	decoder := json.NewDecoder(input)
+	occurrences := []dwca.Occurrence{}
+
+	for {
+		page := occurrencePage{}
+		err := decoder.Decode(&page)
+
+		if err == io.EOF {
+			return occurrences, nil
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal occurrences. %s", err.Error())
+		}
+
+		occurrences = append(occurrences, page.Records...)
+	}
+}
+
+func export(in string, out string, dataset dwca.Dataset) error {
+	var input io.Reader = os.Stdin
+
+	if in != "" {
+		f, err := os.Open(in)
+
+		if err != nil {
+			return err
+		}
+
+		defer f.Close()
+		input = f
+	}
+
+	occurrences, err := readOccurrences(input)
+
+	if err != nil {
+		return err
+	}
+
+	if dataset.Title == "" {
+		dataset.Title = dataset.Collection
+	}
+
+	f, err := os.Create(out)
+
+	if err != nil {
+		return err
+	}
+
+	err = dwca.WriteArchive(f, dataset, occurrences)
+
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	err = f.Close()
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Wrote %d occurrences to %s\n", len(occurrences), out)
+	return nil
+}
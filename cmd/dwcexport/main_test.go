@@ -0,0 +1,27 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadOccurrencesOfEveryPage(t *testing.T) {
+	pages := `{"records":[{"occurrenceID":"0"},{"occurrenceID":"a"}],"fetchedCount":2,"bookmark":"b"}
+{"records":[{"occurrenceID":"b"}],"fetchedCount":1,"bookmark":""}`
+
+	occurrences, err := readOccurrences(strings.NewReader(pages))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(occurrences) != 3 || occurrences[0].OccurrenceID != "0" || occurrences[2].OccurrenceID != "b" {
+		t.Errorf("Read %+v", occurrences)
+	}
+
+	_, err = readOccurrences(strings.NewReader(`[{"occurrenceID":"0"}]`))
+
+	if err == nil {
+		t.Errorf("Read an array of occurrences as a page")
+	}
+}
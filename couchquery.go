@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// queryPermissions caches the collections read while filtering rich query results so each is only read once
+type queryPermissions struct {
+	user        *User
+	collections map[string]*Collection
+}
+
+func newQueryPermissions(user *User) *queryPermissions {
+	return &queryPermissions{user, make(map[string]*Collection)}
+}
+
+func (p *queryPermissions) collection(ctx contractapi.TransactionContextInterface, name string) (*Collection, error) {
+	if collect, ok := p.collections[name]; ok {
+		return collect, nil
+	}
+
+	collectionBytes, err := getState(ctx, collectionObjectType, name)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	collect := new(Collection)
+
+	//A specimen of a missing collection is treated as one nobody may query
+	if collectionBytes != nil {
+		_ = json.Unmarshal(collectionBytes, collect)
+	}
+
+	p.collections[name] = collect
+	return collect, nil
+}
+
+func (p *queryPermissions) canQueryCollection(ctx contractapi.TransactionContextInterface, name string) (bool, error) {
+	collect, err := p.collection(ctx, name)
+
+	if err != nil {
+		return false, err
+	}
+
+	role := roleIn(p.user, name)
+
+	return collect.Query != "" && strings.Contains(collect.Query, role), nil
+}
+
+func (p *queryPermissions) canQuery(ctx contractapi.TransactionContextInterface, specimen *Specimen) (bool, error) {
+	return p.canQueryCollection(ctx, specimen.Collection)
+}
+
+// authorizedSpecimens returns only the specimens of a query whose collection grants the user of the permissions the Query role, redacted by their collection's policy
+func authorizedSpecimens(ctx contractapi.TransactionContextInterface, recordIterator shim.StateQueryIteratorInterface, permissions *queryPermissions) ([]QueryResult, error) {
+	results := []QueryResult{}
+
+	for recordIterator.HasNext() {
+		record, err := recordIterator.Next()
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get record from record iterator. %s", err.Error())
+		}
+
+		objectType, attributes, err := ctx.GetStub().SplitCompositeKey(record.Key)
+
+		if err != nil || objectType != specimenObjectType || len(attributes) == 0 {
+			continue
+		}
+
+		specimen := new(Specimen)
+
+		err = json.Unmarshal(record.Value, specimen)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal specimen. %s", err.Error())
+		}
+
+		allowed, err := permissions.canQuery(ctx, specimen)
+
+		if err != nil {
+			return nil, err
+		}
+		if !allowed {
+			continue
+		}
+
+		specimen, err = permissions.redact(ctx, specimen)
+
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, QueryResult{attributes[0], specimen})
+	}
+
+	return results, nil
+}
+
+func (s *SmartContract) AuthorizedCouchQuery(ctx contractapi.TransactionContextInterface, queryString string, username string) ([]QueryResult, error) {
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	recordIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get record iterator from query string. %s", err.Error())
+	}
+	defer recordIterator.Close()
+
+	return authorizedSpecimens(ctx, recordIterator, newQueryPermissions(user))
+}
+
+func (s *SmartContract) AuthorizedCouchQueryPendingTransactions(ctx contractapi.TransactionContextInterface, queryString string, username string) ([][]PendingTransaction, error) {
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	recordIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get record iterator from query string. %s", err.Error())
+	}
+	defer recordIterator.Close()
+
+	specimens, err := authorizedSpecimens(ctx, recordIterator, newQueryPermissions(user))
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := [][]PendingTransaction{}
+
+	for _, specimen := range specimens {
+		pendingTransactions, err := getPendingTransactions(ctx, specimen.Guid)
+
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, pendingTransactions)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"sort"
+	"testing"
+)
+
+// registerHerpetology registers a collection managed by curator whose specimens only managers and curators may query, with one specimen "h1"
+func registerHerpetology(h *contractHarness) {
+	h.t.Helper()
+	h.ok("RegisterCollection", "KU Herpetology", "curator", "M", "M", "M", "M", "M", "M", "M", "M", "M", "M", "M", "MC", "M")
+	h.ok("CreateSpecimen", `{"guid":"h1","updater":"curator","collection":"KU Herpetology","taxon":"Crotalus horridus"}`)
+}
+
+// linkAs submits the following transactions with an identity linked to the user
+func linkAs(h *contractHarness, username string) {
+	h.t.Helper()
+	h.as(username, map[string]string{usernameAttribute: username})
+	h.ok("LinkIdentity", username)
+}
+
+func resultGuids(results []QueryResult) []string {
+	guids := []string{}
+	for _, result := range results {
+		guids = append(guids, result.Guid)
+	}
+	sort.Strings(guids)
+	return guids
+}
+
+func equalGuids(guids []string, expected ...string) bool {
+	if len(guids) != len(expected) {
+		return false
+	}
+	for i := range guids {
+		if guids[i] != expected[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestQueryAllSpecimensLeavesOutCollectionsCallerMayNotQuery(t *testing.T) {
+	h := newContractHarness(t)
+	registerHerpetology(h)
+
+	results := []QueryResult{}
+	h.okInto(&results, "QueryAllSpecimens")
+
+	if guids := resultGuids(results); !equalGuids(guids, "0") {
+		t.Errorf("Unlinked identity queried %v", guids)
+	}
+
+	linkAs(h, "curator")
+	h.okInto(&results, "QueryAllSpecimens")
+
+	if guids := resultGuids(results); !equalGuids(guids, "0", "h1") {
+		t.Errorf("Curator queried %v", guids)
+	}
+}
+
+func TestCouchQueryLeavesOutCollectionsCallerMayNotQuery(t *testing.T) {
+	h := newContractHarness(t)
+	registerHerpetology(h)
+
+	specimens := []Specimen{}
+	h.okInto(&specimens, "CouchQuery", `{"selector":{}}`)
+
+	if len(specimens) != 1 || specimens[0].Collection != "KU Ornithology" {
+		t.Errorf("Unlinked identity queried %+v", specimens)
+	}
+
+	pendingTransactions := [][]PendingTransaction{}
+	h.okInto(&pendingTransactions, "CouchQueryPendingTransactions", `{"selector":{}}`)
+
+	if len(pendingTransactions) != 1 {
+		t.Errorf("Unlinked identity queried the pending transactions of %d specimens", len(pendingTransactions))
+	}
+
+	linkAs(h, "curator")
+	h.okInto(&specimens, "CouchQuery", `{"selector":{}}`)
+
+	if len(specimens) != 2 {
+		t.Errorf("Curator queried %d specimens", len(specimens))
+	}
+
+	h.okInto(&pendingTransactions, "CouchQueryPendingTransactions", `{"selector":{}}`)
+
+	if len(pendingTransactions) != 2 {
+		t.Errorf("Curator queried the pending transactions of %d specimens", len(pendingTransactions))
+	}
+}
+
+func TestAuthorizedCouchQueryChecksUsersRole(t *testing.T) {
+	h := newContractHarness(t)
+	registerHerpetology(h)
+
+	results := []QueryResult{}
+	h.okInto(&results, "AuthorizedCouchQuery", `{"selector":{}}`, "student")
+
+	if guids := resultGuids(results); !equalGuids(guids, "0") {
+		t.Errorf("Student queried %v", guids)
+	}
+
+	h.okInto(&results, "AuthorizedCouchQuery", `{"selector":{}}`, "curator")
+
+	if guids := resultGuids(results); !equalGuids(guids, "0", "h1") {
+		t.Errorf("Curator queried %v", guids)
+	}
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Determination is one identification of a specimen. A specimen's determinations are kept in the order they were recorded, and the specimen's
+// taxon, determiner and determineDate are those of its current determination.
+type Determination struct {
+	Taxon      string `json:"taxon"`
+	Qualifier  string `json:"qualifier"`
+	Determiner string `json:"determiner"`
+	Date       string `json:"date"`
+	Method     string `json:"method"`
+	Remarks    string `json:"remarks"`
+	//How sure the determiner is, such as the confidence given with an approved taxon suggestion
+	Confidence string `json:"confidence"`
+	Current    bool   `json:"current"`
+	Recorder   string `json:"recorder"`
+	TxID       string `json:"txId"`
+	Recorded   string `json:"recorded"`
+}
+
+type DeterminationHistory struct {
+	Determinations []Determination `json:"determinations"`
+	Current        *Determination  `json:"current"`
+}
+
+// getDeterminations returns a specimen's determinations. A specimen determined before determinations were kept starts with its taxon as its only, current determination.
+func getDeterminations(ctx contractapi.TransactionContextInterface, guid string, specimen *Specimen) ([]Determination, error) {
+	determinationsBytes, err := getState(ctx, determinationObjectType, guid)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	determinations := []Determination{}
+
+	if determinationsBytes != nil {
+		_ = json.Unmarshal(determinationsBytes, &determinations)
+	} else if specimen.Taxon != "" || specimen.Determiner != "" {
+		determinations = append(determinations, Determination{specimen.Taxon, "", specimen.Determiner, specimen.DetermineDate, "", "", "", true, "", "", ""})
+	}
+
+	return determinations, nil
+}
+
+// appendDetermination appends a determination to a specimen's determinations, making it the only current one if it is current
+func appendDetermination(ctx contractapi.TransactionContextInterface, determinations []Determination, determination Determination) ([]Determination, error) {
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	determination.TxID = ctx.GetStub().GetTxID()
+	determination.Recorded = now.Format(time.RFC3339)
+
+	if determination.Current {
+		for i := range determinations {
+			determinations[i].Current = false
+		}
+	}
+
+	return append(determinations, determination), nil
+}
+
+// recordDetermination appends a determination to a specimen's determinations and returns them.
+// specimen is the specimen as it was before the determination, so that the determination it already had is not lost.
+func recordDetermination(ctx contractapi.TransactionContextInterface, guid string, specimen *Specimen, determination Determination) ([]Determination, error) {
+	determinations, err := getDeterminations(ctx, guid, specimen)
+
+	if err != nil {
+		return nil, err
+	}
+
+	determinations, err = appendDetermination(ctx, determinations, determination)
+
+	if err != nil {
+		return nil, err
+	}
+
+	determinationsBytes, _ := json.Marshal(determinations)
+
+	err = putState(ctx, determinationObjectType, guid, determinationsBytes)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return determinations, nil
+}
+
+// determinationHistory returns a specimen's determinations along with its current one
+func determinationHistory(determinations []Determination) *DeterminationHistory {
+	history := DeterminationHistory{determinations, nil}
+
+	for i := range determinations {
+		if determinations[i].Current {
+			history.Current = &determinations[i]
+		}
+	}
+
+	return &history
+}
+
+// AddDetermination records a determination of a specimen. A current determination also becomes the specimen's taxon, determiner and determineDate,
+// while a determination which is not current (e.g. an earlier identification found on an old label) is only added to the history.
+// The determinations returned are those just written, since the transaction cannot read back its own writes.
+func (s *SmartContract) AddDetermination(ctx contractapi.TransactionContextInterface, guid string, username string, taxon string, qualifier string, determiner string, date string, method string, remarks string, current bool) (*DeterminationHistory, error) {
+	if taxon == "" {
+		return nil, fmt.Errorf("A determination requires a taxon")
+	}
+
+	if current {
+		patch := SpecimenPatch{Guid: guid, Updater: username, Taxon: &taxon, Determiner: &determiner, DetermineDate: &date, TaxonQualifier: qualifier, DeterminationMethod: method, DeterminationRemarks: remarks}
+		_, determinations, err := s.patchSpecimen(ctx, "AddDetermination", &patch)
+
+		if err != nil {
+			return nil, err
+		}
+
+		//The patch only sets the taxon, determiner and date, so it is rejected as unchanged unless it recorded a determination
+		return determinationHistory(determinations), nil
+	}
+
+	specimen, err := getSpecimen(ctx, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	username = user.Username
+
+	collect, err := getCollection(ctx, specimen.Collection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	role := roleIn(user, specimen.Collection)
+
+	if !strings.Contains(collect.TaxonName, role) {
+		return nil, fmt.Errorf("%s has role %s but role %s is required to update taxon name", username, role, collect.TaxonName)
+	}
+
+	if date != "" {
+		if message := checkDate(collect, date); message != "" {
+			return nil, &ValidationError{guid, []FieldError{{"determineDate", date, message}}}
+		}
+	}
+
+	determinations, err := recordDetermination(ctx, guid, specimen, Determination{taxon, qualifier, determiner, date, method, remarks, "", false, username, "", ""})
+
+	if err != nil {
+		return nil, err
+	}
+
+	attributionString := fmt.Sprintf("Added determination %s to specimen with GUID %s", taxon, guid)
+	err = attribute(ctx, username, "AddDetermination", determinationObjectType, guid, guid, attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "AddDetermination", ObjectType: determinationObjectType, ID: guid, Actor: username, ChangedFields: []string{"determinations"}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return determinationHistory(determinations), nil
+}
+
+func (s *SmartContract) QueryDeterminations(ctx contractapi.TransactionContextInterface, guid string, username string) (*DeterminationHistory, error) {
+	specimen, err := getSpecimen(ctx, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = collectionQueryAccess(ctx, specimen.Collection, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	determinations, err := getDeterminations(ctx, guid, specimen)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return determinationHistory(determinations), nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSpecimenStartsWithItsTaxonAsDetermination(t *testing.T) {
+	h := newContractHarness(t)
+
+	history := DeterminationHistory{}
+	h.okInto(&history, "QueryDeterminations", "0", "public")
+
+	if len(history.Determinations) != 1 || history.Current == nil || history.Current.Taxon != "Pygoplites diacanthus" || history.Current.Determiner != "Greenfield, David W" {
+		t.Errorf("Sample specimen's determinations are %+v", history)
+	}
+}
+
+func TestAddDeterminationReturnsDeterminationsWritten(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.fail("AddDetermination", "0", "assistant", "Pomacanthus imperator", "", "Label", "", "label", "", "false")
+	h.fail("AddDetermination", "0", "manager", "", "", "Label", "", "label", "", "false")
+
+	history := DeterminationHistory{}
+	h.okInto(&history, "AddDetermination", "0", "manager", "Pomacanthus imperator", "cf.", "Label", "", "label", "from old label", "false")
+
+	if len(history.Determinations) != 2 || history.Current == nil || history.Current.Taxon != "Pygoplites diacanthus" {
+		t.Errorf("Added earlier determination gave %+v", history)
+	}
+
+	recorded := h.now()
+	h.okInto(&history, "AddDetermination", "0", "manager", "Centropyge loricula", "aff.", "Smith, J", "01/01/2021", "DNA barcode", "", "true")
+
+	if len(history.Determinations) != 3 || history.Current == nil || history.Current.Taxon != "Centropyge loricula" || history.Current.Qualifier != "aff." || history.Current.Recorder != "manager" {
+		t.Errorf("Added current determination gave %+v", history)
+	}
+	if history.Current.TxID != h.tx || history.Current.Recorded != recorded.Format("2006-01-02T15:04:05Z07:00") {
+		t.Errorf("Current determination was recorded by %s at %s", history.Current.TxID, history.Current.Recorded)
+	}
+
+	for _, determination := range history.Determinations[:2] {
+		if determination.Current {
+			t.Errorf("%s is still current", determination.Taxon)
+		}
+	}
+
+	queried := DeterminationHistory{}
+	h.okInto(&queried, "QueryDeterminations", "0", "public")
+
+	if len(queried.Determinations) != 3 || *queried.Current != *history.Current {
+		t.Errorf("Queried determinations are %+v", queried)
+	}
+
+	specimen := Specimen{}
+	h.okInto(&specimen, "Query", "0", "public")
+
+	if specimen.Taxon != "Centropyge loricula" || specimen.Determiner != "Smith, J" || specimen.DetermineDate != "01/01/2021" {
+		t.Errorf("Current determination left specimen %+v", specimen)
+	}
+}
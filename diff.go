@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// FieldChange is a specimen field whose value differs between two versions. Lists are given as JSON arrays.
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type SpecimenChange struct {
+	TxID      string        `json:"txId"`
+	Timestamp string        `json:"timestamp"`
+	Updater   string        `json:"updater"`
+	IsDelete  bool          `json:"isDelete"`
+	Hidden    bool          `json:"hidden"`
+	Changes   []FieldChange `json:"changes"`
+}
+
+// fieldValue returns a specimen field as a string, leaving empty lists blank like empty strings
+func fieldValue(value reflect.Value) string {
+	if value.Kind() == reflect.String {
+		return value.String()
+	}
+
+	if value.Kind() == reflect.Slice && value.Len() == 0 {
+		return ""
+	}
+
+	valueBytes, _ := json.Marshal(value.Interface())
+	return string(valueBytes)
+}
+
+// fieldChanges returns the fields which differ between two versions of a specimen. A nil version is one which does not exist, such as before a specimen's creation.
+func fieldChanges(oldSpecimen *Specimen, newSpecimen *Specimen) []FieldChange {
+	if oldSpecimen == nil {
+		oldSpecimen = new(Specimen)
+	}
+	if newSpecimen == nil {
+		newSpecimen = new(Specimen)
+	}
+
+	oldStruct := reflect.ValueOf(oldSpecimen).Elem()
+	newStruct := reflect.ValueOf(newSpecimen).Elem()
+
+	changes := []FieldChange{}
+
+	for i := 0; i < newStruct.NumField(); i++ {
+		oldValue, newValue := fieldValue(oldStruct.Field(i)), fieldValue(newStruct.Field(i))
+
+		if oldValue != newValue {
+			changes = append(changes, FieldChange{jsonName(newStruct.Type().Field(i)), oldValue, newValue})
+		}
+	}
+
+	return changes
+}
+
+// withheld reports whether a version is hidden due to vandalism and its specimen was left out for the user's role
+func (entry *HistoryEntry) withheld() bool {
+	return entry.Hidden && entry.Record == nil && !entry.IsDelete
+}
+
+// GetHistoryChanges returns the fields changed by every transaction in a specimen's history, oldest first.
+// A version hidden from the user is listed without changes, and the next version is compared with the last version the user may see.
+func (s *SmartContract) GetHistoryChanges(ctx contractapi.TransactionContextInterface, guid string, username string) ([]SpecimenChange, error) {
+	entries, err := specimenHistory(ctx, guid, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := []SpecimenChange{}
+	var previous *Specimen
+
+	for _, entry := range entries {
+		change := SpecimenChange{entry.TxID, entry.Timestamp, entry.Updater, entry.IsDelete, entry.Hidden, []FieldChange{}}
+
+		if !entry.withheld() {
+			change.Changes = fieldChanges(previous, entry.Record)
+			previous = entry.Record
+		}
+
+		results = append(results, change)
+	}
+
+	return results, nil
+}
+
+// DiffSpecimenVersions returns the fields which differ between the versions of a specimen written by two transactions
+func (s *SmartContract) DiffSpecimenVersions(ctx contractapi.TransactionContextInterface, guid string, username string, fromTxID string, toTxID string) ([]FieldChange, error) {
+	entries, err := specimenHistory(ctx, guid, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make(map[string]*HistoryEntry)
+	for i := range entries {
+		versions[entries[i].TxID] = &entries[i]
+	}
+
+	for _, txid := range []string{fromTxID, toTxID} {
+		entry, ok := versions[txid]
+
+		if !ok {
+			return nil, fmt.Errorf("%s is not a transaction in the history of specimen %s", txid, guid)
+		}
+		if entry.withheld() {
+			return nil, fmt.Errorf("The version of specimen %s written by %s is hidden due to vandalism", guid, txid)
+		}
+	}
+
+	return fieldChanges(versions[fromTxID].Record, versions[toTxID].Record), nil
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+)
+
+// changedFieldNames returns the fields of a list of changes
+func changedFieldNames(changes []FieldChange) []string {
+	fields := []string{}
+	for _, change := range changes {
+		fields = append(fields, change.Field)
+	}
+	return fields
+}
+
+func TestGetHistoryChanges(t *testing.T) {
+	h := newContractHarness(t)
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"manager","preparation":"skin"}`)
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"curator","notes":"spam"}`)
+	vandalized := h.tx
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"manager","preparation":"skeleton"}`)
+	h.ok("Hide", "0", "manager", vandalized)
+
+	changes := []SpecimenChange{}
+	h.okInto(&changes, "GetHistoryChanges", "0", "public")
+
+	if len(changes) != 5 {
+		t.Fatalf("Changes are %+v", changes)
+	}
+
+	if fields := changedFieldNames(changes[1].Changes); !equalGuids(fields, "preparation") {
+		t.Errorf("First patch changed %v", fields)
+	}
+
+	//The hidden version is skipped, so the next one is compared with the version before it
+	if !changes[2].Hidden || len(changes[2].Changes) != 0 {
+		t.Errorf("Hidden version shows %+v", changes[2])
+	}
+
+	if fields := changedFieldNames(changes[3].Changes); !equalGuids(fields, "preparation", "notes") {
+		t.Errorf("Version after the hidden one changed %v", fields)
+	}
+
+	h.okInto(&changes, "GetHistoryChanges", "0", "assistant")
+
+	if fields := changedFieldNames(changes[2].Changes); !equalGuids(fields, "updater", "notes") {
+		t.Errorf("Assistant sees hidden version change %v", fields)
+	}
+}
+
+func TestDiffSpecimenVersions(t *testing.T) {
+	h := newContractHarness(t)
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"manager","preparation":"skin","collector":"Smith, J"}`)
+	first := h.tx
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"curator","notes":"spam"}`)
+	vandalized := h.tx
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"manager","preparation":"skeleton"}`)
+	last := h.tx
+	h.ok("Hide", "0", "manager", vandalized)
+
+	changes := []FieldChange{}
+	h.okInto(&changes, "DiffSpecimenVersions", "0", "public", first, last)
+
+	if len(changes) != 2 || changes[0] != (FieldChange{"preparation", "skin", "skeleton"}) || changes[1].Field != "notes" {
+		t.Errorf("Changes are %+v", changes)
+	}
+
+	h.fail("DiffSpecimenVersions", "0", "public", first, vandalized)
+	h.fail("DiffSpecimenVersions", "0", "public", first, "missing")
+	h.fail("DiffSpecimenVersions", "0", "nobody", first, last)
+
+	h.okInto(&changes, "DiffSpecimenVersions", "0", "assistant", vandalized, first)
+
+	if fields := changedFieldNames(changes); !equalGuids(fields, "updater", "notes") {
+		t.Errorf("Assistant sees changes to %v", fields)
+	}
+}
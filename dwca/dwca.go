@@ -0,0 +1,256 @@
+// Package dwca writes Darwin Core Archives (https://dwc.tdwg.org/text/) of specimen occurrences for ingestion by GBIF, iDigBio and other aggregators.
+package dwca
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	termNamespace     = "http://rs.tdwg.org/dwc/terms/"
+	occurrenceRowType = termNamespace + "Occurrence"
+	occurrenceFile    = "occurrence.txt"
+	metaFile          = "meta.xml"
+	emlFile           = "eml.xml"
+)
+
+// Occurrence is one specimen record. Its json tags are the Darwin Core term names, and occurrenceID is the archive's core id.
+type Occurrence struct {
+	OccurrenceID             string `json:"occurrenceID"`
+	BasisOfRecord            string `json:"basisOfRecord"`
+	CollectionCode           string `json:"collectionCode"`
+	CatalogNumber            string `json:"catalogNumber"`
+	OtherCatalogNumbers      string `json:"otherCatalogNumbers"`
+	RecordedBy               string `json:"recordedBy"`
+	RecordNumber             string `json:"recordNumber"`
+	EventDate                string `json:"eventDate"`
+	VerbatimEventDate        string `json:"verbatimEventDate"`
+	Habitat                  string `json:"habitat"`
+	Locality                 string `json:"locality"`
+	DecimalLatitude          string `json:"decimalLatitude"`
+	DecimalLongitude         string `json:"decimalLongitude"`
+	ScientificName           string `json:"scientificName"`
+	ScientificNameAuthorship string `json:"scientificNameAuthorship"`
+	TaxonRank                string `json:"taxonRank"`
+	IdentifiedBy             string `json:"identifiedBy"`
+	DateIdentified           string `json:"dateIdentified"`
+	Preparations             string `json:"preparations"`
+	AssociatedMedia          string `json:"associatedMedia"`
+	InformationWithheld      string `json:"informationWithheld"`
+}
+
+// Terms are the Darwin Core terms written for every occurrence, in column order
+var Terms = []string{"occurrenceID", "basisOfRecord", "collectionCode", "catalogNumber", "otherCatalogNumbers", "recordedBy", "recordNumber", "eventDate", "verbatimEventDate", "habitat", "locality", "decimalLatitude", "decimalLongitude", "scientificName", "scientificNameAuthorship", "taxonRank", "identifiedBy", "dateIdentified", "preparations", "associatedMedia", "informationWithheld"}
+
+// SpecimenFields are the specimen fields Darwin Core terms are imported into, the reverse of how specimens are exported as occurrences
+var SpecimenFields = map[string]string{
+	"occurrenceID":        "guid",
+	"collectionCode":      "collection",
+	"catalogNumber":       "catalogNumber",
+	"otherCatalogNumbers": "accessionNumber",
+	"recordedBy":          "collector",
+	"recordNumber":        "fieldNumber",
+	"eventDate":           "fieldDate",
+	"habitat":             "habitat",
+	"locality":            "location",
+	"decimalLatitude":     "latitude",
+	"decimalLongitude":    "longitude",
+	"scientificName":      "taxon",
+	"identifiedBy":        "determiner",
+	"dateIdentified":      "determineDate",
+	"preparations":        "preparation",
+	"associatedMedia":     "image",
+}
+
+// TermName strips the namespace from a Darwin Core term URI, leaving names which are already short as they are
+func TermName(term string) string {
+	return strings.TrimPrefix(term, termNamespace)
+}
+
+func (o *Occurrence) values() []string {
+	return []string{o.OccurrenceID, o.BasisOfRecord, o.CollectionCode, o.CatalogNumber, o.OtherCatalogNumbers, o.RecordedBy, o.RecordNumber, o.EventDate, o.VerbatimEventDate, o.Habitat, o.Locality, o.DecimalLatitude, o.DecimalLongitude, o.ScientificName, o.ScientificNameAuthorship, o.TaxonRank, o.IdentifiedBy, o.DateIdentified, o.Preparations, o.AssociatedMedia, o.InformationWithheld}
+}
+
+// Dataset describes the archive in its EML metadata document
+type Dataset struct {
+	Title       string
+	Institution string
+	Collection  string
+	Contact     string
+	Email       string
+	Description string
+	License     string
+	PubDate     time.Time
+}
+
+// fieldReplacer keeps values from breaking the tab separated rows of occurrence.txt
+var fieldReplacer = strings.NewReplacer("\t", " ", "\r\n", " ", "\n", " ", "\r", " ")
+
+// WriteOccurrences writes occurrence.txt, a tab separated table with a header row of term names
+func WriteOccurrences(w io.Writer, occurrences []Occurrence) error {
+	_, err := io.WriteString(w, strings.Join(Terms, "\t")+"\n")
+
+	if err != nil {
+		return err
+	}
+
+	for i := range occurrences {
+		values := occurrences[i].values()
+
+		for j, value := range values {
+			values[j] = fieldReplacer.Replace(value)
+		}
+
+		_, err = io.WriteString(w, strings.Join(values, "\t")+"\n")
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type metaField struct {
+	Index int    `xml:"index,attr"`
+	Term  string `xml:"term,attr"`
+}
+
+type metaID struct {
+	Index int `xml:"index,attr"`
+}
+
+type metaCore struct {
+	Encoding           string      `xml:"encoding,attr"`
+	FieldsTerminatedBy string      `xml:"fieldsTerminatedBy,attr"`
+	LinesTerminatedBy  string      `xml:"linesTerminatedBy,attr"`
+	FieldsEnclosedBy   string      `xml:"fieldsEnclosedBy,attr"`
+	IgnoreHeaderLines  int         `xml:"ignoreHeaderLines,attr"`
+	RowType            string      `xml:"rowType,attr"`
+	Location           string      `xml:"files>location"`
+	ID                 metaID      `xml:"id"`
+	Fields             []metaField `xml:"field"`
+}
+
+type metaArchive struct {
+	XMLName  xml.Name `xml:"http://rs.tdwg.org/dwc/text/ archive"`
+	Metadata string   `xml:"metadata,attr"`
+	Core     metaCore `xml:"core"`
+}
+
+// WriteMeta writes meta.xml, the descriptor mapping the columns of occurrence.txt to Darwin Core terms
+func WriteMeta(w io.Writer) error {
+	core := metaCore{"UTF-8", "\\t", "\\n", "", 1, occurrenceRowType, occurrenceFile, metaID{0}, nil}
+
+	for i, term := range Terms {
+		core.Fields = append(core.Fields, metaField{i, termNamespace + term})
+	}
+
+	return writeXML(w, metaArchive{Metadata: emlFile, Core: core})
+}
+
+type emlName struct {
+	SurName string `xml:"surName"`
+}
+
+type emlParty struct {
+	Organization string   `xml:"organizationName,omitempty"`
+	Name         *emlName `xml:"individualName,omitempty"`
+	Email        string   `xml:"electronicMailAddress,omitempty"`
+}
+
+type emlDataset struct {
+	Title              string   `xml:"title"`
+	Creator            emlParty `xml:"creator"`
+	PubDate            string   `xml:"pubDate"`
+	Abstract           string   `xml:"abstract>para"`
+	IntellectualRights string   `xml:"intellectualRights>para,omitempty"`
+	Contact            emlParty `xml:"contact"`
+}
+
+type emlDocument struct {
+	XMLName    xml.Name   `xml:"eml:eml"`
+	EMLNS      string     `xml:"xmlns:eml,attr"`
+	PackageID  string     `xml:"packageId,attr"`
+	System     string     `xml:"system,attr"`
+	Dataset    emlDataset `xml:"dataset"`
+	Collection string     `xml:"additionalMetadata>metadata>gbif>collection>collectionName,omitempty"`
+}
+
+// WriteEML writes eml.xml, the Ecological Metadata Language document describing the dataset
+func WriteEML(w io.Writer, dataset Dataset) error {
+	creator := emlParty{dataset.Institution, nil, dataset.Email}
+
+	if dataset.Contact != "" {
+		creator.Name = &emlName{dataset.Contact}
+	}
+	description := dataset.Description
+
+	if description == "" {
+		description = fmt.Sprintf("Specimen occurrences of %s", dataset.Collection)
+	}
+
+	document := emlDocument{
+		EMLNS:      "eml://ecoinformatics.org/eml-2.1.1",
+		PackageID:  dataset.Collection,
+		System:     "biodiversity",
+		Dataset:    emlDataset{dataset.Title, creator, dataset.PubDate.Format("2006-01-02"), description, dataset.License, creator},
+		Collection: dataset.Collection,
+	}
+
+	return writeXML(w, document)
+}
+
+func writeXML(w io.Writer, document interface{}) error {
+	_, err := io.WriteString(w, xml.Header)
+
+	if err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+
+	err = encoder.Encode(document)
+
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(w, "\n")
+	return err
+}
+
+// WriteArchive writes a zipped Darwin Core Archive holding occurrence.txt, meta.xml and eml.xml
+func WriteArchive(w io.Writer, dataset Dataset, occurrences []Occurrence) error {
+	archive := zip.NewWriter(w)
+
+	files := []struct {
+		name  string
+		write func(io.Writer) error
+	}{
+		{occurrenceFile, func(f io.Writer) error { return WriteOccurrences(f, occurrences) }},
+		{metaFile, WriteMeta},
+		{emlFile, func(f io.Writer) error { return WriteEML(f, dataset) }},
+	}
+
+	for _, file := range files {
+		f, err := archive.Create(file.name)
+
+		if err != nil {
+			return fmt.Errorf("Failed to add %s to archive. %s", file.name, err.Error())
+		}
+
+		err = file.write(f)
+
+		if err != nil {
+			return fmt.Errorf("Failed to write %s. %s", file.name, err.Error())
+		}
+	}
+
+	return archive.Close()
+}
@@ -0,0 +1,99 @@
+package dwca
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readArchive returns the contents of every file in a zipped archive by name
+func readArchive(t *testing.T, archive []byte) map[string]string {
+	t.Helper()
+
+	reader, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]string)
+
+	for _, file := range reader.File {
+		f, err := file.Open()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		contents, err := ioutil.ReadAll(f)
+		f.Close()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files[file.Name] = string(contents)
+	}
+
+	return files
+}
+
+func TestWriteArchive(t *testing.T) {
+	occurrences := []Occurrence{
+		{OccurrenceID: "0", BasisOfRecord: "PreservedSpecimen", CollectionCode: "KU Ornithology", CatalogNumber: "32581", ScientificName: "Pygoplites diacanthus", DecimalLatitude: "18.1483325958", DecimalLongitude: "-178.3984985352"},
+		{OccurrenceID: "1", BasisOfRecord: "PreservedSpecimen", CollectionCode: "KU Ornithology", Habitat: "reef\tslope\nfore reef", InformationWithheld: "collector"},
+	}
+	dataset := Dataset{Title: "KU Ornithology", Institution: "University of Kansas", Collection: "KU Ornithology", PubDate: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	var buffer bytes.Buffer
+	err := WriteArchive(&buffer, dataset, occurrences)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := readArchive(t, buffer.Bytes())
+
+	if len(files) != 3 || files[emlFile] == "" {
+		t.Fatalf("Archive holds %d files", len(files))
+	}
+
+	meta := metaArchive{}
+	err = xml.Unmarshal([]byte(files[metaFile]), &meta)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if meta.Metadata != emlFile || meta.Core.RowType != occurrenceRowType || meta.Core.Location != occurrenceFile || meta.Core.ID.Index != 0 || meta.Core.IgnoreHeaderLines != 1 {
+		t.Errorf("meta.xml describes %+v", meta.Core)
+	}
+
+	if len(meta.Core.Fields) != len(Terms) || meta.Core.Fields[1] != (metaField{1, termNamespace + "basisOfRecord"}) {
+		t.Errorf("meta.xml maps fields %+v", meta.Core.Fields)
+	}
+
+	rows := strings.Split(strings.TrimSuffix(files[occurrenceFile], "\n"), "\n")
+
+	if len(rows) != 3 || rows[0] != strings.Join(Terms, "\t") {
+		t.Fatalf("occurrence.txt is %q", files[occurrenceFile])
+	}
+
+	//Every row has a value for every term, in the order meta.xml gives them
+	first := strings.Split(rows[1], "\t")
+
+	if !reflect.DeepEqual(first, occurrences[0].values()) || first[13] != "Pygoplites diacanthus" {
+		t.Errorf("First occurrence is %q", first)
+	}
+
+	second := strings.Split(rows[2], "\t")
+
+	if len(second) != len(Terms) || second[9] != "reef slope fore reef" || second[20] != "collector" {
+		t.Errorf("Second occurrence is %q", second)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// ChangeEvent is the payload of the chaincode event set by every state-changing transaction.
+// Fabric keeps only one event per transaction, so each transaction sets exactly one ChangeEvent named after its action.
+type ChangeEvent struct {
+	Action        string   `json:"action"`
+	ObjectType    string   `json:"objectType"`
+	ID            string   `json:"id"`
+	Actor         string   `json:"actor"`
+	ChangedFields []string `json:"changedFields"`
+	AffectedIDs   []string `json:"affectedIds"`
+	TxID          string   `json:"txId"`
+}
+
+func emitEvent(ctx contractapi.TransactionContextInterface, event ChangeEvent) error {
+	event.TxID = ctx.GetStub().GetTxID()
+
+	if event.ChangedFields == nil {
+		event.ChangedFields = []string{}
+	}
+	if event.AffectedIDs == nil {
+		event.AffectedIDs = []string{}
+	}
+
+	eventBytes, _ := json.Marshal(event)
+	err := ctx.GetStub().SetEvent(event.Action, eventBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to set %s event. %s", event.Action, err.Error())
+	}
+
+	return nil
+}
+
+// changedFields lists the JSON names of the fields that differ between two values of the same struct type
+func changedFields(oldValue interface{}, newValue interface{}) []string {
+	oldStruct := reflect.Indirect(reflect.ValueOf(oldValue))
+	newStruct := reflect.Indirect(reflect.ValueOf(newValue))
+
+	fields := []string{}
+
+	for i := 0; i < newStruct.NumField(); i++ {
+		if reflect.DeepEqual(oldStruct.Field(i).Interface(), newStruct.Field(i).Interface()) {
+			continue
+		}
+
+		fields = append(fields, jsonName(newStruct.Type().Field(i)))
+	}
+
+	return fields
+}
+
+func jsonName(field reflect.StructField) string {
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+
+	if name == "" {
+		return field.Name
+	}
+
+	return name
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTransactionsEmitChangeEvents(t *testing.T) {
+	h := newContractHarness(t)
+
+	loan := Loan{}
+	h.okInto(&loan, "OpenLoan", "0", "manager", "left wing", "AMNH", "J Doe", "2020-01-01", "2020-02-01")
+	event := h.lastEvent()
+
+	if event.Action != "OpenLoan" || event.ObjectType != loanObjectType || event.ID != loan.ID || event.Actor != "manager" || event.TxID != h.tx {
+		t.Errorf("OpenLoan emitted %+v", event)
+	}
+	if !reflect.DeepEqual(event.AffectedIDs, []string{"0"}) {
+		t.Errorf("OpenLoan affected %v", event.AffectedIDs)
+	}
+
+	h.ok("UpdateTaxonClass", "KU Ornithology", "manager", "Pygoplites diacanthus", "Pomacanthus imperator")
+	event = h.lastEvent()
+
+	if event.Action != "UpdateTaxonClass" || !reflect.DeepEqual(event.AffectedIDs, []string{"0"}) {
+		t.Errorf("UpdateTaxonClass emitted %+v", event)
+	}
+}
+
+func TestAdminTransactionEventsCreditSubmittingIdentity(t *testing.T) {
+	h := newContractHarness(t)
+	h.asAdmin()
+
+	h.ok("SetIdentityMode", identityModeStrict)
+	h.ok("SetTaxonValidation", "false")
+	h.ok("MigrateKeys", "100", "")
+	h.ok("IndexSpecimens")
+
+	for _, event := range h.stub.events[len(h.stub.events)-4:] {
+		if event.Actor != "Org1MSP::CN=admin,O=Org1" {
+			t.Errorf("%s credited %q", event.Action, event.Actor)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"ku.edu/hyperledger/chaincode/biodiversity/dwca"
+)
+
+type OccurrencePage struct {
+	Records      []dwca.Occurrence `json:"records"`
+	FetchedCount int32             `json:"fetchedCount"`
+	Bookmark     string            `json:"bookmark"`
+}
+
+// exportDate converts a date in one of the collection's accepted forms to ISO 8601, to the precision it was recorded with
+func exportDate(collect *Collection, date string) string {
+	for _, layout := range dateLayouts(collect) {
+		parsed, err := time.Parse(layout, date)
+
+		if err != nil {
+			continue
+		}
+
+		//A date recorded without a day or month is not given one
+		fields := strings.Replace(layout, "2006", "", 1)
+
+		switch {
+		case strings.Contains(fields, "2"):
+			return parsed.Format(isoDateLayout)
+		case strings.Contains(fields, "1"):
+			return parsed.Format("2006-01")
+		default:
+			return parsed.Format("2006")
+		}
+	}
+
+	return ""
+}
+
+func exportCoordinate(coordinate string, limit float64) string {
+	value, err := strconv.ParseFloat(coordinate, 64)
+
+	if err != nil || value < -limit || value > limit {
+		return ""
+	}
+
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// publishedVersion returns the latest version of a specimen whose data was not written by a hidden transaction, or nil if every version is hidden.
+// Hide and Unhide rewrite a specimen without changing its data, so each version is traced back to the transaction which wrote its data.
+func publishedVersion(ctx contractapi.TransactionContextInterface, guid string, specimen *Specimen) (*Specimen, error) {
+	if len(specimen.VandalizedTransactions) == 0 {
+		return specimen, nil
+	}
+
+	hidden := make(map[string]bool)
+	for _, txid := range specimen.VandalizedTransactions {
+		hidden[txid] = true
+	}
+
+	history, err := specimenVersions(ctx, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	type version struct {
+		specimen *Specimen
+		source   string
+	}
+
+	versions := []version{}
+
+	for _, historical := range history {
+		if historical.specimen != nil {
+			historical.specimen.VandalizedTransactions = nil
+		}
+
+		versions = append(versions, version{historical.specimen, historical.txID})
+	}
+
+	for i := 1; i < len(versions); i++ {
+		if versions[i].specimen != nil && cmp.Equal(versions[i].specimen, versions[i-1].specimen) {
+			versions[i].source = versions[i-1].source
+		}
+	}
+
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i].specimen != nil && !hidden[versions[i].source] {
+			return versions[i].specimen, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// occurrence maps a specimen of a collection to Darwin Core terms. Loans, grants, condition, notes and updaters are internal and never exported.
+func occurrence(ctx contractapi.TransactionContextInterface, collect *Collection, guid string, specimen *Specimen) (*dwca.Occurrence, error) {
+	record := dwca.Occurrence{
+		OccurrenceID:        guid,
+		BasisOfRecord:       "PreservedSpecimen",
+		CollectionCode:      specimen.Collection,
+		CatalogNumber:       specimen.CatalogNumber,
+		OtherCatalogNumbers: specimen.AccessionNumber,
+		RecordedBy:          specimen.Collector,
+		RecordNumber:        specimen.FieldNumber,
+		EventDate:           exportDate(collect, specimen.FieldDate),
+		VerbatimEventDate:   specimen.FieldDate,
+		Habitat:             specimen.Habitat,
+		Locality:            specimen.Location,
+		DecimalLatitude:     exportCoordinate(specimen.Latitude, 90),
+		DecimalLongitude:    exportCoordinate(specimen.Longitude, 180),
+		ScientificName:      specimen.Taxon,
+		IdentifiedBy:        specimen.Determiner,
+		DateIdentified:      exportDate(collect, specimen.DetermineDate),
+		Preparations:        specimen.Preparation,
+		AssociatedMedia:     specimen.Image,
+	}
+
+	if specimen.LocalityHash != "" {
+		record.InformationWithheld = "Precise locality withheld; locality and coordinates are generalized"
+	}
+
+	if specimen.Taxon != "" {
+		taxonIDs, err := indexedIDs(ctx, taxonNameIndex, []string{specimen.Taxon})
+
+		if err != nil {
+			return nil, err
+		}
+
+		//Homonyms cannot be told apart by name, so only a name registered once is described
+		if len(taxonIDs) == 1 {
+			taxon, err := getTaxon(ctx, taxonIDs[0])
+
+			if err != nil {
+				return nil, err
+			}
+
+			record.TaxonRank = taxon.Rank
+			record.ScientificNameAuthorship = taxon.Author
+
+			if taxon.Year != "" {
+				record.ScientificNameAuthorship += ", " + taxon.Year
+			}
+		}
+	}
+
+	return &record, nil
+}
+
+// ExportDarwinCore returns one page of the specimens of a collection as Darwin Core occurrences, for packaging into a Darwin Core Archive with the dwcexport command.
+// A page may hold fewer occurrences than were fetched when some specimens are not published.
+func (s *SmartContract) ExportDarwinCore(ctx contractapi.TransactionContextInterface, collection string, username string, pageSize int32, bookmark string) (*OccurrencePage, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("Page size must be positive")
+	}
+
+	permissions, err := collectionQueryPermissions(ctx, collection, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	collect, err := permissions.collection(ctx, collection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	guids, metadata, err := pagedIndexedIDs(ctx, collectionTaxonIndex, []string{collection}, pageSize, bookmark)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := []dwca.Occurrence{}
+
+	for _, guid := range guids {
+		specimen, err := getSpecimen(ctx, guid)
+
+		if err != nil {
+			return nil, err
+		}
+
+		published, err := publishedVersion(ctx, guid, specimen)
+
+		if err != nil {
+			return nil, err
+		}
+
+		specimen = generalizedVersion(published, specimen)
+
+		//A specimen whose published version belonged to another collection is not published by this one
+		if specimen == nil || specimen.Collection != collection {
+			continue
+		}
+
+		specimen, err = permissions.redact(ctx, specimen)
+
+		if err != nil {
+			return nil, err
+		}
+
+		record, err := occurrence(ctx, collect, guid, specimen)
+
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, *record)
+	}
+
+	return &OccurrencePage{results, metadata.FetchedRecordsCount, metadata.Bookmark}, nil
+}
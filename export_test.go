@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// exportAllPages exports every page of a collection and returns the occurrence ids in order
+func exportAllPages(h *contractHarness, collection string, username string, pageSize int32) ([]string, int) {
+	h.t.Helper()
+	occurrenceIDs := []string{}
+	bookmark := ""
+	pages := 0
+
+	for {
+		page := OccurrencePage{}
+		h.okInto(&page, "ExportDarwinCore", collection, username, fmt.Sprint(pageSize), bookmark)
+		for _, record := range page.Records {
+			occurrenceIDs = append(occurrenceIDs, record.OccurrenceID)
+		}
+		bookmark = page.Bookmark
+		pages++
+
+		if page.FetchedCount < pageSize {
+			sort.Strings(occurrenceIDs)
+			return occurrenceIDs, pages
+		}
+	}
+}
+
+func TestExportDarwinCoreInPages(t *testing.T) {
+	h := newContractHarness(t)
+	createSpecimens(h, "Pomacanthus imperator", "a", "b")
+	createSpecimens(h, "Centropyge loricula", "c", "d")
+
+	h.fail("ExportDarwinCore", "KU Ornithology", "nobody", "2", "")
+	h.fail("ExportDarwinCore", "KU Ornithology", "public", "0", "")
+
+	first := OccurrencePage{}
+	h.okInto(&first, "ExportDarwinCore", "KU Ornithology", "public", "2", "")
+
+	if len(first.Records) != 2 || first.FetchedCount != 2 || first.Bookmark == "" {
+		t.Errorf("First page is %+v", first)
+	}
+
+	occurrenceIDs, pages := exportAllPages(h, "KU Ornithology", "public", 2)
+
+	if !equalGuids(occurrenceIDs, "0", "a", "b", "c", "d") || pages != 3 {
+		t.Errorf("Exported %v in %d pages", occurrenceIDs, pages)
+	}
+}
+
+func TestExportDarwinCoreMapsTerms(t *testing.T) {
+	h := newContractHarness(t)
+	asTaxonomist(h)
+	h.ok("AddTaxon", "s1", "manager", "Pygoplites diacanthus", "species", "", "Boddaert", "1772")
+
+	page := OccurrencePage{}
+	h.okInto(&page, "ExportDarwinCore", "KU Ornithology", "public", "10", "")
+
+	if len(page.Records) != 1 {
+		t.Fatalf("Exported %+v", page.Records)
+	}
+
+	record := page.Records[0]
+
+	if record.OccurrenceID != "0" || record.BasisOfRecord != "PreservedSpecimen" || record.CollectionCode != "KU Ornithology" || record.IdentifiedBy != "Greenfield, David W" {
+		t.Errorf("Exported %+v", record)
+	}
+	if record.TaxonRank != "species" || record.ScientificNameAuthorship != "Boddaert, 1772" {
+		t.Errorf("Exported taxon %s %s", record.TaxonRank, record.ScientificNameAuthorship)
+	}
+	if record.DecimalLatitude != "18.1483325958" || record.DecimalLongitude != "-178.3984985352" {
+		t.Errorf("Exported coordinates %s, %s", record.DecimalLatitude, record.DecimalLongitude)
+	}
+}
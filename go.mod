@@ -3,6 +3,9 @@ module ku.edu/hyperledger/chaincode/biodiversity
 go 1.13
 
 require (
+	github.com/golang/protobuf v1.3.2
 	github.com/google/go-cmp v0.5.2
+	github.com/hyperledger/fabric-chaincode-go v0.0.0-20200424173110-d7076418f212
 	github.com/hyperledger/fabric-contract-api-go v1.1.0
+	github.com/hyperledger/fabric-protos-go v0.0.0-20200424173316-dd554ba3746e
 )
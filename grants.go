@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	grantStatusActive    = "active"
+	grantStatusFulfilled = "fulfilled"
+	grantStatusRevoked   = "revoked"
+)
+
+type Grant struct {
+	ID              string `json:"id"`
+	Guid            string `json:"guid"`
+	Collection      string `json:"collection"`
+	Grantee         string `json:"grantee"`
+	Purpose         string `json:"purpose"`
+	ConsumedParts   string `json:"consumedParts"`
+	PermittedUses   string `json:"permittedUses"`
+	GrantDate       string `json:"grantDate"`
+	ExpiryDate      string `json:"expiryDate"`
+	Acknowledgement string `json:"acknowledgement"`
+	Deliverables    string `json:"deliverables"`
+	Status          string `json:"status"`
+	Registrar       string `json:"registrar"`
+	ResolutionDate  string `json:"resolutionDate"`
+	ResolutionNotes string `json:"resolutionNotes"`
+	Resolver        string `json:"resolver"`
+}
+
+func getGrant(ctx contractapi.TransactionContextInterface, grantID string) (*Grant, error) {
+	grantBytes, err := getState(ctx, grantObjectType, grantID)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if grantBytes == nil {
+		return nil, fmt.Errorf("Grant %s does not exist", grantID)
+	}
+
+	grant := new(Grant)
+	_ = json.Unmarshal(grantBytes, grant)
+
+	return grant, nil
+}
+
+func putGrant(ctx contractapi.TransactionContextInterface, grant *Grant) error {
+	err := putIndex(ctx, true, specimenGrantIndex, grant.Guid, grant.ID)
+
+	if err != nil {
+		return err
+	}
+
+	err = putIndex(ctx, true, granteeGrantIndex, grant.Grantee, grant.ID)
+
+	if err != nil {
+		return err
+	}
+
+	grantBytes, _ := json.Marshal(grant)
+
+	err = putState(ctx, grantObjectType, grant.ID, grantBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+func (s *SmartContract) IssueGrant(ctx contractapi.TransactionContextInterface, guid string, username string, grantee string, purpose string, consumedParts string, permittedUses string, grantDate string, expiryDate string, acknowledgement string, deliverables string) (*Grant, error) {
+	specimen, err := getSpecimen(ctx, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	username = user.Username
+
+	collect, err := getCollection(ctx, specimen.Collection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	role := roleIn(user, specimen.Collection)
+
+	if !strings.Contains(collect.RegisterUse, role) {
+		return nil, fmt.Errorf("%s has role %s but role %s is required to register usage grants", username, role, collect.RegisterUse)
+	}
+
+	if grantee == "" || purpose == "" {
+		return nil, fmt.Errorf("A grant requires a grantee and a purpose")
+	}
+	if err := checkISODate("Grant date", grantDate); err != nil {
+		return nil, err
+	}
+	//Grants without an expiry date never expire
+	if expiryDate != "" {
+		if err := checkISODate("Expiry date", expiryDate); err != nil {
+			return nil, err
+		}
+		if expiryDate < grantDate {
+			return nil, fmt.Errorf("Expiry date %s is before grant date %s", expiryDate, grantDate)
+		}
+	}
+
+	grant := Grant{ctx.GetStub().GetTxID(), guid, specimen.Collection, grantee, purpose, consumedParts, permittedUses, grantDate, expiryDate, acknowledgement, deliverables, grantStatusActive, username, "", "", ""}
+
+	err = putGrant(ctx, &grant)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attributionString := fmt.Sprintf("Issued grant %s for specimen with GUID %s", grant.ID, guid)
+	err = attribute(ctx, username, "IssueGrant", grantObjectType, grant.ID, guid, attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "IssueGrant", ObjectType: grantObjectType, ID: grant.ID, Actor: username, ChangedFields: changedFields(Grant{}, grant), AffectedIDs: []string{guid}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &grant, nil
+}
+
+// resolveGrant moves an active grant to its final fulfilled or revoked status
+func resolveGrant(ctx contractapi.TransactionContextInterface, action string, grantID string, username string, status string, date string, notes string) (*Grant, error) {
+	grant, err := getGrant(ctx, grantID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	username = user.Username
+
+	collect, err := getCollection(ctx, grant.Collection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	role := roleIn(user, grant.Collection)
+
+	if !strings.Contains(collect.RegisterUse, role) {
+		return nil, fmt.Errorf("%s has role %s but role %s is required to register usage grants", username, role, collect.RegisterUse)
+	}
+
+	if grant.Status != grantStatusActive {
+		return nil, fmt.Errorf("Grant %s is already %s", grantID, grant.Status)
+	}
+	if err := checkISODate("Resolution date", date); err != nil {
+		return nil, err
+	}
+
+	oldGrant := *grant
+
+	grant.Status = status
+	grant.ResolutionDate = date
+	grant.ResolutionNotes = notes
+	grant.Resolver = username
+
+	err = putGrant(ctx, grant)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attributionString := fmt.Sprintf("Marked grant %s for specimen with GUID %s as %s", grantID, grant.Guid, status)
+	err = attribute(ctx, username, action, grantObjectType, grantID, grant.Guid, attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: action, ObjectType: grantObjectType, ID: grantID, Actor: username, ChangedFields: changedFields(&oldGrant, grant), AffectedIDs: []string{grant.Guid}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return grant, nil
+}
+
+func (s *SmartContract) FulfillGrant(ctx contractapi.TransactionContextInterface, grantID string, username string, date string, notes string) (*Grant, error) {
+	return resolveGrant(ctx, "FulfillGrant", grantID, username, grantStatusFulfilled, date, notes)
+}
+
+func (s *SmartContract) RevokeGrant(ctx contractapi.TransactionContextInterface, grantID string, username string, date string, reason string) (*Grant, error) {
+	if reason == "" {
+		return nil, fmt.Errorf("A reason is required to revoke a grant")
+	}
+
+	return resolveGrant(ctx, "RevokeGrant", grantID, username, grantStatusRevoked, date, reason)
+}
+
+func (s *SmartContract) QueryGrant(ctx contractapi.TransactionContextInterface, grantID string, username string) (*Grant, error) {
+	grant, err := getGrant(ctx, grantID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = unwithheldQueryPermissions(ctx, grant.Collection, username, grantObjectType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return grant, nil
+}
+
+func (s *SmartContract) QuerySpecimenGrants(ctx contractapi.TransactionContextInterface, guid string, username string) ([]Grant, error) {
+	specimen, err := getSpecimen(ctx, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = unwithheldQueryPermissions(ctx, specimen.Collection, username, grantObjectType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	grantIDs, err := indexedIDs(ctx, specimenGrantIndex, []string{guid})
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := []Grant{}
+
+	for _, grantID := range grantIDs {
+		grant, err := getGrant(ctx, grantID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, *grant)
+	}
+
+	return results, nil
+}
+
+// QueryGranteeGrants returns the grants to a grantee across every collection the user may query and whose grants are not withheld from the user
+func (s *SmartContract) QueryGranteeGrants(ctx contractapi.TransactionContextInterface, grantee string, username string) ([]Grant, error) {
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	grantIDs, err := indexedIDs(ctx, granteeGrantIndex, []string{grantee})
+
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := newQueryPermissions(user)
+	results := []Grant{}
+
+	for _, grantID := range grantIDs {
+		grant, err := getGrant(ctx, grantID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		allowed, err := permissions.canQueryCollection(ctx, grant.Collection)
+
+		if err != nil {
+			return nil, err
+		}
+
+		withheld, err := permissions.withholds(ctx, grant.Collection, grantObjectType)
+
+		if err != nil {
+			return nil, err
+		}
+		if allowed && !withheld {
+			results = append(results, *grant)
+		}
+	}
+
+	return results, nil
+}
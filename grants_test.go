@@ -0,0 +1,80 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func issueGrant(h *contractHarness, guid string, username string) Grant {
+	h.t.Helper()
+	grant := Grant{}
+	h.okInto(&grant, "IssueGrant", guid, username, "Dr X", "DNA", "1g muscle", "sequencing only", "2020-01-01", "2021-01-01", "Cite KU", "GenBank accession")
+	return grant
+}
+
+func TestGrantLifecycle(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.fail("IssueGrant", "0", "public", "Dr X", "DNA", "1g muscle", "sequencing only", "2020-01-01", "2021-01-01", "Cite KU", "GenBank accession")
+	h.fail("IssueGrant", "0", "manager", "", "DNA", "", "", "2020-01-01", "", "", "")
+
+	grant := issueGrant(h, "0", "manager")
+
+	h.fail("RevokeGrant", grant.ID, "manager", "2020-05-01", "")
+	h.okInto(&grant, "FulfillGrant", grant.ID, "manager", "2020-05-01", "done")
+
+	if grant.Status != grantStatusFulfilled {
+		t.Errorf("Fulfilled grant has status %s", grant.Status)
+	}
+
+	h.fail("FulfillGrant", grant.ID, "manager", "2020-05-01", "done")
+
+	grants := []Grant{}
+	h.okInto(&grants, "QuerySpecimenGrants", "0", "public")
+
+	if len(grants) != 1 || grants[0].ID != grant.ID {
+		t.Errorf("Specimen has grants %+v", grants)
+	}
+}
+
+func TestHiddenGrants(t *testing.T) {
+	h := newContractHarness(t)
+	registerHerpetology(h)
+
+	grant := issueGrant(h, "0", "manager")
+	issueGrant(h, "h1", "curator")
+	h.ok("SetRedactionPolicy", "KU Ornithology", "manager", "SP", "-1", "false", "false", "true")
+
+	for _, query := range [][]string{
+		{"QueryGrant", grant.ID, "public"},
+		{"QuerySpecimenGrants", "0", "public"},
+	} {
+		message := h.fail(query[0], query[1:]...)
+
+		if !strings.Contains(message, "Grants of collection KU Ornithology are withheld from role P") {
+			t.Errorf("%s failed with %q", query[0], message)
+		}
+
+		h.ok(query[0], query[1], "assistant")
+	}
+
+	//Grants spanning collections leave out those the user may not see rather than failing
+	grants := []Grant{}
+	h.okInto(&grants, "QueryGranteeGrants", "Dr X", "public")
+
+	if len(grants) != 0 {
+		t.Errorf("Public user queried grants %+v", grants)
+	}
+
+	h.okInto(&grants, "QueryGranteeGrants", "Dr X", "assistant")
+
+	if len(grants) != 1 || grants[0].ID != grant.ID {
+		t.Errorf("Assistant queried grants %+v", grants)
+	}
+
+	h.okInto(&grants, "QueryGranteeGrants", "Dr X", "curator")
+
+	if len(grants) != 2 {
+		t.Errorf("Curator queried %d grants", len(grants))
+	}
+}
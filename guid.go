@@ -0,0 +1,122 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// guidNamespace is the RFC 4122 URL namespace, under which minted guids are name-based (version 5) UUIDs of the transaction that created them
+var guidNamespace = []byte{0x6b, 0xa7, 0xb8, 0x11, 0x9d, 0xad, 0x11, 0xd1, 0x80, 0xb4, 0x00, 0xc0, 0x4f, 0xd4, 0x30, 0xc8}
+
+// mintGuid derives the guid of the nth specimen created by a transaction from the transaction id, so that every endorsing peer mints the same guid.
+// Collections with a guid prefix get the prefix followed by 16 hex digits of the UUID instead of the UUID itself.
+func mintGuid(ctx contractapi.TransactionContextInterface, collect *Collection, n int) string {
+	hash := sha1.New()
+	hash.Write(guidNamespace)
+	hash.Write([]byte(fmt.Sprintf("biodiversity:%s:%d", ctx.GetStub().GetTxID(), n)))
+	uuid := hash.Sum(nil)[:16]
+
+	uuid[6] = (uuid[6] & 0x0f) | 0x50
+	uuid[8] = (uuid[8] & 0x3f) | 0x80
+
+	digits := hex.EncodeToString(uuid)
+
+	if collect.GuidPrefix != "" {
+		return collect.GuidPrefix + digits[:16]
+	}
+
+	return strings.Join([]string{digits[:8], digits[8:12], digits[12:16], digits[16:20], digits[20:]}, "-")
+}
+
+// MintSpecimen creates a new specimen from a SpecimenPatch without a guid, minting its guid from the transaction id, and returns the guid and specimen
+func (s *SmartContract) MintSpecimen(ctx contractapi.TransactionContextInterface, specimenPatch string) (*QueryResult, error) {
+	patch := new(SpecimenPatch)
+	err := json.Unmarshal([]byte(specimenPatch), patch)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal specimen patch. %s", err.Error())
+	}
+
+	if patch.Guid != "" {
+		return nil, fmt.Errorf("MintSpecimen mints the guid of the new specimen, so the patch must not have one")
+	}
+
+	specimen := Specimen{Updater: patch.Updater, VandalizedTransactions: []string{}}
+	patch.applyTo(&specimen)
+
+	collect, err := getCollection(ctx, specimen.Collection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	guid := mintGuid(ctx, collect, 0)
+
+	created, err := s.create(ctx, "MintSpecimen", guid, specimen, patch.conditionDate())
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &QueryResult{guid, created}, nil
+}
+
+// SetGuidPrefix sets the prefix of the guids minted for a collection's specimens, or mints UUIDs when the prefix is blank
+func (s *SmartContract) SetGuidPrefix(ctx contractapi.TransactionContextInterface, name string, username string, prefix string) (*Collection, error) {
+	collect, err := getCollection(ctx, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	username = user.Username
+
+	if role, ok := user.Membership[name]; ok {
+		if role != "M" {
+			return nil, fmt.Errorf("%s is not the Manager for collection %s", username, name)
+		}
+	} else {
+		return nil, fmt.Errorf("%s is not registered with collection %s", username, name)
+	}
+
+	//Guids are composite key attributes, which may not hold U+0000
+	if strings.ContainsRune(prefix, 0) {
+		return nil, fmt.Errorf("A guid prefix may not contain U+0000")
+	}
+
+	oldCollection := *collect
+	collect.GuidPrefix = prefix
+
+	attributionString := fmt.Sprintf("Updated Collection %s guid prefix", name)
+	err = attribute(ctx, username, "SetGuidPrefix", collectionObjectType, name, "", attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	collectionBytes, _ := json.Marshal(collect)
+	err = putState(ctx, collectionObjectType, name, collectionBytes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "SetGuidPrefix", ObjectType: collectionObjectType, ID: name, Actor: username, ChangedFields: changedFields(&oldCollection, collect)})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return collect, nil
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var uuidVersion5 = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestMintSpecimen(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.fail("MintSpecimen", `{"guid":"x","updater":"manager","collection":"KU Ornithology"}`)
+
+	minted := QueryResult{}
+	h.okInto(&minted, "MintSpecimen", `{"updater":"manager","collection":"KU Ornithology","taxon":"Pomacanthus imperator"}`)
+
+	if !uuidVersion5.MatchString(minted.Guid) || minted.Record == nil || minted.Record.Taxon != "Pomacanthus imperator" {
+		t.Errorf("Minted %+v", minted)
+	}
+
+	h.ok("Query", minted.Guid, "manager")
+
+	h.fail("SetGuidPrefix", "KU Ornithology", "curator", "urn:catalog:KU:Orn:")
+	h.ok("SetGuidPrefix", "KU Ornithology", "manager", "urn:catalog:KU:Orn:")
+
+	prefixed := QueryResult{}
+	h.okInto(&prefixed, "MintSpecimen", `{"updater":"manager","collection":"KU Ornithology"}`)
+
+	if !strings.HasPrefix(prefixed.Guid, "urn:catalog:KU:Orn:") || len(prefixed.Guid) != len("urn:catalog:KU:Orn:")+16 {
+		t.Errorf("Minted %s with the guid prefix", prefixed.Guid)
+	}
+}
+
+func TestCreateSpecimensMintsMissingGuids(t *testing.T) {
+	h := newContractHarness(t)
+
+	result := BatchResult{}
+	h.okInto(&result, "CreateSpecimens", `[{"updater":"manager","collection":"KU Ornithology"},{"updater":"manager","collection":"KU Ornithology"},{"guid":"given","updater":"manager","collection":"KU Ornithology"}]`, "false")
+
+	if result.Created != 3 || !uuidVersion5.MatchString(result.Rows[0].Guid) || result.Rows[0].Guid == result.Rows[1].Guid || result.Rows[2].Guid != "given" {
+		t.Errorf("Batch result is %+v", result)
+	}
+
+	for _, row := range result.Rows {
+		h.ok("Query", row.Guid, "manager")
+	}
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+type HistoryEntry struct {
+	TxID      string `json:"txId"`
+	Timestamp string `json:"timestamp"`
+	IsDelete  bool   `json:"isDelete"`
+	//Versions hidden due to vandalism only carry their specimen for roles which may hide and unhide versions
+	Hidden  bool      `json:"hidden"`
+	Record  *Specimen `json:"specimen"`
+	Updater string    `json:"updater"`
+}
+
+// EntityHistoryEntry is one version of a user, collection or attribution returned by GetEntityHistory. Value is null for a delete.
+type EntityHistoryEntry struct {
+	TxID      string          `json:"TxId"`
+	Value     json.RawMessage `json:"Value"`
+	Timestamp string          `json:"Timestamp"`
+	IsDelete  bool            `json:"IsDelete"`
+}
+
+// specimenVersion is one version of a specimen in its ledger history. specimen is nil for a delete.
+type specimenVersion struct {
+	txID      string
+	timestamp time.Time
+	specimen  *Specimen
+}
+
+// keyVersions appends the versions of a specimen in the ledger history of one key
+func keyVersions(ctx contractapi.TransactionContextInterface, key string, versions []specimenVersion, legacy bool) ([]specimenVersion, error) {
+	recordIterator, err := ctx.GetStub().GetHistoryForKey(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	defer recordIterator.Close()
+
+	for recordIterator.HasNext() {
+		response, err := recordIterator.Next()
+
+		if err != nil {
+			return nil, fmt.Errorf("Error. %s", err.Error())
+		}
+
+		var historical *Specimen
+
+		if response.IsDelete {
+			//MigrateKeys deleting the flat key moved the specimen rather than deleting it
+			if legacy {
+				continue
+			}
+		} else {
+			//A flat key holds a specimen only if it was stored under its bare guid
+			if legacy {
+				if objectType, _ := legacyObjectType(key, response.Value); objectType != specimenObjectType {
+					continue
+				}
+			}
+
+			historical = new(Specimen)
+			_ = json.Unmarshal(response.Value, historical)
+		}
+
+		versions = append(versions, specimenVersion{response.TxId, time.Unix(response.Timestamp.Seconds, int64(response.Timestamp.Nanos)).UTC(), historical})
+	}
+
+	return versions, nil
+}
+
+// specimenVersions returns every version of a specimen in its ledger history, oldest first.
+// Versions written before MigrateKeys moved the specimen to its composite key are read from its flat key.
+func specimenVersions(ctx contractapi.TransactionContextInterface, guid string) ([]specimenVersion, error) {
+	key, err := stateKey(ctx, specimenObjectType, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := keyVersions(ctx, guid, []specimenVersion{}, true)
+
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err = keyVersions(ctx, key, versions, false)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].timestamp.Before(versions[j].timestamp)
+	})
+
+	return versions, nil
+}
+
+// specimenActors maps the transactions credited to a specimen's contributors to the user who made them.
+// A specimen's updater is not rewritten by every transaction (e.g. Hide and Unhide), so it does not always name who wrote a version.
+func specimenActors(ctx contractapi.TransactionContextInterface, guid string) (map[string]string, error) {
+	recordIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(specimenContributorIndex, []string{guid})
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get record iterator. %s", err.Error())
+	}
+
+	defer recordIterator.Close()
+
+	actors := make(map[string]string)
+
+	for recordIterator.HasNext() {
+		record, err := recordIterator.Next()
+
+		if err != nil {
+			return nil, fmt.Errorf("Error. %s", err.Error())
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(record.Key)
+
+		if err != nil || len(attributes) < 4 {
+			return nil, fmt.Errorf("Failed to split key %s", record.Key)
+		}
+
+		actors[attributes[3]] = attributes[1]
+	}
+
+	return actors, nil
+}
+
+// historyEntries returns every version of a specimen, oldest first, as the user of the permissions may see it.
+// specimen is the current version, which records the transactions hidden due to vandalism and the generalized locality shown in place of a withheld precise one.
+func historyEntries(ctx contractapi.TransactionContextInterface, guid string, specimen *Specimen, permissions *queryPermissions) ([]HistoryEntry, error) {
+	collect, err := permissions.collection(ctx, specimen.Collection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	hidden := make(map[string]bool)
+	for _, txid := range specimen.VandalizedTransactions {
+		hidden[txid] = true
+	}
+
+	//The roles which may Hide and Unhide versions need to see them to decide whether they are vandalism
+	seesHidden := strings.Contains(collect.SecondaryUpdate, roleIn(permissions.user, specimen.Collection))
+
+	versions, err := specimenVersions(ctx, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	actors, err := specimenActors(ctx, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := []HistoryEntry{}
+
+	for _, version := range versions {
+		entry := HistoryEntry{TxID: version.txID, Timestamp: version.timestamp.Format(time.RFC3339Nano), IsDelete: version.specimen == nil, Hidden: hidden[version.txID], Updater: actors[version.txID]}
+
+		if version.specimen != nil {
+			if entry.Updater == "" {
+				entry.Updater = version.specimen.Updater
+			}
+
+			if !entry.Hidden || seesHidden {
+				entry.Record, err = permissions.redact(ctx, generalizedVersion(version.specimen, specimen))
+
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		results = append(results, entry)
+	}
+
+	return results, nil
+}
+
+// specimenHistory checks that the user may query a specimen and returns every version of it, oldest first, as the user may see it
+func specimenHistory(ctx contractapi.TransactionContextInterface, guid string, username string) ([]HistoryEntry, error) {
+	specimen, err := getSpecimen(ctx, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := collectionQueryPermissions(ctx, specimen.Collection, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return historyEntries(ctx, guid, specimen, permissions)
+}
+
+// GetHistory returns every version of a specimen, oldest first, redacted by the collection's redaction policy
+func (s *SmartContract) GetHistory(ctx contractapi.TransactionContextInterface, guid string, username string) ([]HistoryEntry, error) {
+	return specimenHistory(ctx, guid, username)
+}
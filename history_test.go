@@ -0,0 +1,131 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestGetHistoryChecksQueryRole(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.fail("GetHistory", "0", "nobody")
+	h.fail("GetHistory", "missing", "manager")
+	h.fail("GetEntityHistory", specimenObjectType, "0", "manager")
+	h.fail("GetEntityHistory", "loan", "0", "manager")
+	h.ok("GetEntityHistory", userObjectType, "manager", "manager")
+}
+
+func TestGetHistoryIsTypedAndCreditsUpdaters(t *testing.T) {
+	h := newContractHarness(t)
+	patchedAt := h.now()
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"curator","preparation":"skin"}`)
+	patched := h.tx
+
+	entries := []HistoryEntry{}
+	h.okInto(&entries, "GetHistory", "0", "public")
+
+	if len(entries) != 2 || entries[1].TxID != patched || entries[1].Updater != "curator" || entries[1].Record.Preparation != "skin" {
+		t.Fatalf("History is %+v", entries)
+	}
+
+	if entries[0].Record.Preparation == "skin" || entries[1].Timestamp != patchedAt.Format(time.RFC3339) {
+		t.Errorf("History is %+v", entries)
+	}
+}
+
+func TestHiddenVersionsAreWithheldFromRolesWhichCannotUnhide(t *testing.T) {
+	h := newContractHarness(t)
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"curator","notes":"spam"}`)
+	vandalized := h.tx
+
+	h.fail("Hide", "0", "public", vandalized)
+	h.ok("Hide", "0", "manager", vandalized)
+
+	entries := []HistoryEntry{}
+	h.okInto(&entries, "GetHistory", "0", "public")
+
+	if entry := entries[1]; entry.TxID != vandalized || !entry.Hidden || entry.Record != nil {
+		t.Errorf("Public sees hidden version %+v", entry)
+	}
+
+	h.okInto(&entries, "GetHistory", "0", "assistant")
+
+	if entry := entries[1]; !entry.Hidden || entry.Record == nil || entry.Record.Notes == "" {
+		t.Errorf("Assistant sees hidden version %+v", entry)
+	}
+
+	h.ok("Unhide", "0", "manager", vandalized)
+	h.okInto(&entries, "GetHistory", "0", "public")
+
+	if entry := entries[1]; entry.Hidden || entry.Record == nil {
+		t.Errorf("Public sees unhidden version %+v", entry)
+	}
+}
+
+func TestHideAndUnhideAreCreditedToTheirActor(t *testing.T) {
+	h := newContractHarness(t)
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"curator","notes":"spam"}`)
+	vandalized := h.tx
+
+	h.ok("Hide", "0", "assistant", vandalized)
+	hidden := h.tx
+	h.ok("Unhide", "0", "curator", vandalized)
+	unhidden := h.tx
+
+	//Hiding leaves the specimen's updater as curator, who wrote the vandalized version
+	entries := []HistoryEntry{}
+	h.okInto(&entries, "GetHistory", "0", "manager")
+
+	if len(entries) != 4 || entries[2].TxID != hidden || entries[2].Updater != "assistant" || entries[3].TxID != unhidden || entries[3].Updater != "curator" {
+		t.Errorf("History is %+v", entries)
+	}
+
+	counts := map[string]int{}
+	h.okInto(&counts, "CountContributions", "assistant", "manager")
+
+	if counts["Hide"] != 1 {
+		t.Errorf("Assistant's contributions are %v", counts)
+	}
+}
+
+func TestEntityHistoryChecksAccessAndIsValidJSON(t *testing.T) {
+	h := newContractHarness(t)
+	registerHerpetology(h)
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"curator","preparation":"skin"}`)
+	patched := h.tx
+
+	entries := []EntityHistoryEntry{}
+	h.okInto(&entries, "GetEntityHistory", attributionObjectType, "curator", "curator")
+
+	if len(entries) != 3 {
+		t.Fatalf("Curator's attribution history is %+v", entries)
+	}
+
+	for _, entry := range entries {
+		var description string
+		err := json.Unmarshal(entry.Value, &description)
+
+		if err != nil || description == "" {
+			t.Errorf("Attribution %s is not a JSON string: %v", entry.Value, err)
+		}
+	}
+
+	//The public user may not query KU Herpetology, so only the patch to specimen 0 is visible
+	h.okInto(&entries, "GetEntityHistory", attributionObjectType, "curator", "public")
+
+	if len(entries) != 1 || entries[0].TxID != patched {
+		t.Errorf("Public user read attribution history %+v", entries)
+	}
+
+	h.fail("GetEntityHistory", attributionObjectType, "curator", "nobody")
+	h.fail("GetEntityHistory", collectionObjectType, "KU Herpetology", "public")
+	h.okInto(&entries, "GetEntityHistory", collectionObjectType, "KU Herpetology", "curator")
+
+	collect := Collection{}
+	err := json.Unmarshal(entries[0].Value, &collect)
+
+	if len(entries) != 1 || err != nil || collect.Name != "KU Herpetology" {
+		t.Errorf("Collection history is %+v", entries)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Identity modes control whether transactions may fall back to the caller supplied username.
+// "compatible" trusts the supplied username when the submitting identity is not linked to a user (for existing Node clients sharing one wallet identity).
+// "strict" rejects any transaction whose submitting identity is not linked to a registered user.
+const (
+	identityModeCompatible = "compatible"
+	identityModeStrict     = "strict"
+
+	usernameAttribute   = "biodiversity.username"
+	adminAttribute      = "biodiversity.admin"
+	taxonomistAttribute = "biodiversity.taxonomist"
+)
+
+type Config struct {
+	IdentityMode string `json:"identityMode"`
+	//When set, specimen taxa must be names in the taxonomy registry
+	ValidateTaxa bool `json:"validateTaxa"`
+}
+
+func getConfig(ctx contractapi.TransactionContextInterface) (*Config, error) {
+	configBytes, err := getState(ctx, configObjectType, "")
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	config := new(Config)
+
+	//Deployments that predate the config record keep trusting usernames until migrated
+	if configBytes == nil {
+		config.IdentityMode = identityModeCompatible
+		return config, nil
+	}
+
+	err = json.Unmarshal(configBytes, config)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal config. %s", err.Error())
+	}
+
+	return config, nil
+}
+
+// clientIdentityID identifies the submitting certificate by its MSP ID and X.509 subject
+func clientIdentityID(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to get MSP ID of submitting identity. %s", err.Error())
+	}
+
+	cert, err := ctx.GetClientIdentity().GetX509Certificate()
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to get certificate of submitting identity. %s", err.Error())
+	}
+	if cert == nil {
+		return "", fmt.Errorf("Submitting identity does not have an X.509 certificate")
+	}
+
+	return mspID + "::" + cert.Subject.String(), nil
+}
+
+// resolveCaller returns the username linked to the submitting identity, or "" if there is none
+func resolveCaller(ctx contractapi.TransactionContextInterface) (string, error) {
+	attributeName, found, err := ctx.GetClientIdentity().GetAttributeValue(usernameAttribute)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to read %s attribute of submitting identity. %s", usernameAttribute, err.Error())
+	}
+	if found && attributeName != "" {
+		return attributeName, nil
+	}
+
+	identity, err := clientIdentityID(ctx)
+
+	if err != nil {
+		return "", err
+	}
+
+	linkedName, err := getState(ctx, identityObjectType, identity)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	return string(linkedName), nil
+}
+
+func isAdmin(ctx contractapi.TransactionContextInterface) bool {
+	return ctx.GetClientIdentity().AssertAttributeValue(adminAttribute, "true") == nil
+}
+
+// getUser returns the user acting in the current transaction.
+// The supplied username must match the user linked to the submitting identity; it is only trusted on its own in compatible mode.
+func getUser(ctx contractapi.TransactionContextInterface, username string) (*User, error) {
+	callerName, err := resolveCaller(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	trusted := callerName == ""
+
+	if trusted {
+		config, err := getConfig(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+		if config.IdentityMode != identityModeCompatible {
+			return nil, fmt.Errorf("Submitting identity is not linked to a registered user")
+		}
+
+		callerName = username
+	} else if username != "" && username != callerName {
+		return nil, fmt.Errorf("%s does not match user %s linked to the submitting identity", username, callerName)
+	}
+
+	userBytes, err := getState(ctx, userObjectType, callerName)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if userBytes == nil {
+		return nil, fmt.Errorf("%s does not exist", callerName)
+	}
+
+	user := new(User)
+	_ = json.Unmarshal(userBytes, user)
+
+	//A user who has linked an identity may only act through a linked identity, even in compatible mode
+	if trusted && len(user.Identities) > 0 {
+		return nil, fmt.Errorf("%s is linked to an identity and may not be acted as by an unlinked identity", callerName)
+	}
+
+	if user.Membership == nil {
+		user.Membership = make(map[string]string)
+	}
+
+	return user, nil
+}
+
+func linkIdentity(ctx contractapi.TransactionContextInterface, user *User) error {
+	identity, err := clientIdentityID(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	linkedName, err := getState(ctx, identityObjectType, identity)
+
+	if err != nil {
+		return fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+	if linkedName != nil {
+		return fmt.Errorf("Submitting identity is already linked to user %s", string(linkedName))
+	}
+
+	err = putState(ctx, identityObjectType, identity, []byte(user.Username))
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	user.Identities = append(user.Identities, identity)
+	return nil
+}
+
+func (s *SmartContract) LinkIdentity(ctx contractapi.TransactionContextInterface, username string) error {
+	checkUser, err := getState(ctx, userObjectType, username)
+
+	if err != nil {
+		return fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+	if checkUser == nil {
+		return fmt.Errorf("%s does not exist", username)
+	}
+
+	user := new(User)
+	_ = json.Unmarshal(checkUser, user)
+
+	config, err := getConfig(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	//Only an admin may link identities to a user that has already claimed one, or any user in strict mode.
+	//Anyone else must prove the user is theirs with an enrollment attribute issued by the CA, or the first caller could take any account.
+	if !isAdmin(ctx) {
+		if config.IdentityMode != identityModeCompatible {
+			return fmt.Errorf("Only an identity with the %s attribute may link identities in %s mode", adminAttribute, config.IdentityMode)
+		}
+		if len(user.Identities) > 0 {
+			return fmt.Errorf("%s is already linked to an identity", username)
+		}
+		if ctx.GetClientIdentity().AssertAttributeValue(usernameAttribute, username) != nil {
+			return fmt.Errorf("Only an identity with the %s attribute or the %s=%s attribute may link itself to %s", adminAttribute, usernameAttribute, username, username)
+		}
+	}
+
+	err = linkIdentity(ctx, user)
+
+	if err != nil {
+		return err
+	}
+
+	userBytes, _ := json.Marshal(user)
+	err = putState(ctx, userObjectType, username, userBytes)
+
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, ChangeEvent{Action: "LinkIdentity", ObjectType: userObjectType, ID: username, Actor: username, ChangedFields: []string{"identities"}})
+}
+
+func (s *SmartContract) SetIdentityMode(ctx contractapi.TransactionContextInterface, mode string) error {
+	if mode != identityModeCompatible && mode != identityModeStrict {
+		return fmt.Errorf("%s is not a valid identity mode. Valid modes are %s and %s", mode, identityModeCompatible, identityModeStrict)
+	}
+
+	if !isAdmin(ctx) {
+		return fmt.Errorf("Only an identity with the %s attribute may change the identity mode", adminAttribute)
+	}
+
+	config, err := getConfig(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	oldConfig := *config
+	config.IdentityMode = mode
+	configBytes, _ := json.Marshal(config)
+	err = putState(ctx, configObjectType, "", configBytes)
+
+	if err != nil {
+		return err
+	}
+
+	actor, err := clientIdentityID(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, ChangeEvent{Action: "SetIdentityMode", ObjectType: configObjectType, Actor: actor, ChangedFields: changedFields(&oldConfig, config)})
+}
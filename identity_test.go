@@ -0,0 +1,97 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCompatibleModeTrustsUsernameOfUnlinkedIdentity(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.ok("Query", "0", "manager")
+	h.fail("Query", "0", "nobody")
+}
+
+func TestLinkedIdentityActsOnlyAsItsUser(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.ok("RegisterUser", "alice")
+	h.as("alice", map[string]string{usernameAttribute: "alice"})
+	h.ok("LinkIdentity", "alice")
+
+	h.ok("Query", "0", "alice")
+	h.ok("Query", "0", "")
+
+	message := h.fail("Query", "0", "manager")
+
+	if !strings.Contains(message, "does not match user alice") {
+		t.Errorf("Unexpected error %q", message)
+	}
+}
+
+func TestUnlinkedIdentityMayNotActAsLinkedUser(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.ok("RegisterUser", "alice")
+	h.asAdmin()
+	h.ok("LinkIdentity", "alice")
+
+	h.as("app", nil)
+	message := h.fail("Query", "0", "alice")
+
+	if !strings.Contains(message, "alice is linked to an identity") {
+		t.Errorf("Unexpected error %q", message)
+	}
+}
+
+func TestStrictModeRejectsUnlinkedIdentity(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.as("app", nil)
+	h.fail("SetIdentityMode", identityModeStrict)
+
+	h.asAdmin()
+	h.fail("SetIdentityMode", "lenient")
+	h.ok("SetIdentityMode", identityModeStrict)
+
+	h.as("app", nil)
+	h.fail("Query", "0", "manager")
+	h.fail("QueryAllSpecimens")
+	h.fail("QueryAllSpecimensWithPagination", "10", "")
+
+	//RegisterUser links the submitting identity in strict mode
+	h.as("bob", nil)
+	h.ok("RegisterUser", "bob")
+	h.ok("Query", "0", "bob")
+	h.ok("Query", "0", "")
+	h.fail("Query", "0", "manager")
+	h.ok("QueryAllSpecimens")
+}
+
+func TestUsernameAttributeIdentifiesUser(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.asAdmin()
+	h.ok("SetIdentityMode", identityModeStrict)
+
+	h.as("x", map[string]string{usernameAttribute: "manager"})
+	h.ok("Query", "0", "manager")
+	h.fail("Query", "0", "curator")
+}
+
+func TestStrangerMayNotLinkToExistingUser(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.as("stranger", nil)
+	h.fail("LinkIdentity", "manager")
+
+	h.as("stranger", map[string]string{usernameAttribute: "curator"})
+	h.fail("LinkIdentity", "manager")
+
+	//The real client still acts as the manager through the shared unlinked identity
+	h.as("app", nil)
+	h.ok("Query", "0", "manager")
+
+	h.as("manager", map[string]string{usernameAttribute: "manager"})
+	h.ok("LinkIdentity", "manager")
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Every entity is stored under a composite key of its object type so that, for example, a user named "0" cannot collide with specimen "0"
+const (
+	specimenObjectType    = "specimen"
+	userObjectType        = "user"
+	collectionObjectType  = "collection"
+	pendingObjectType     = "pending"
+	attributionObjectType = "attribution"
+	identityObjectType    = "identity"
+	configObjectType      = "config"
+	loanObjectType        = "loan"
+	grantObjectType       = "grant"
+	//Contributions are keyed by actor, transaction, action and target so that no contribution overwrites another
+	contributionObjectType    = "contribution"
+	taxonSuggestionObjectType = "taxonSuggestion"
+	auxiliaryObjectType       = "auxiliary"
+	determinationObjectType   = "determination"
+	taxonObjectType           = "taxon"
+	//Precise localities are kept in a private data collection rather than world state
+	preciseLocalityObjectType = "preciseLocality"
+
+	//Index keys hold no data of their own and point at the entity named by their last attribute
+	openLoanIndex     = "openLoan"
+	specimenLoanIndex = "specimenLoan"
+
+	specimenGrantIndex = "specimenGrant"
+	granteeGrantIndex  = "granteeGrant"
+
+	//Specimen contributor keys are read for their actor and action attributes rather than followed to a contribution
+	specimenContributorIndex = "specimenContributor"
+
+	specimenTaxonSuggestionIndex = "specimenTaxonSuggestion"
+	pendingTaxonSuggestionIndex  = "pendingTaxonSuggestion"
+
+	specimenAuxiliaryIndex = "specimenAuxiliary"
+
+	collectionTaxonIndex = "collectionTaxon"
+
+	//Catalog and accession numbers are unique within a collection, so their keys normally point at a single specimen
+	collectionCatalogNumberIndex   = "collectionCatalogNumber"
+	collectionAccessionNumberIndex = "collectionAccessionNumber"
+
+	taxonNameIndex    = "taxonName"
+	taxonChildIndex   = "taxonChild"
+	taxonSynonymIndex = "taxonSynonym"
+)
+
+func stateKey(ctx contractapi.TransactionContextInterface, objectType string, attributes ...string) (string, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(objectType, attributes)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to create %s key. %s", objectType, err.Error())
+	}
+
+	return key, nil
+}
+
+func getState(ctx contractapi.TransactionContextInterface, objectType string, id string) ([]byte, error) {
+	key, err := stateKey(ctx, objectType, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ctx.GetStub().GetState(key)
+}
+
+func putState(ctx contractapi.TransactionContextInterface, objectType string, id string, value []byte) error {
+	key, err := stateKey(ctx, objectType, id)
+
+	if err != nil {
+		return err
+	}
+
+	return ctx.GetStub().PutState(key, value)
+}
+
+// keyID returns the id a composite key was created with
+func keyID(ctx contractapi.TransactionContextInterface, key string) (string, error) {
+	_, attributes, err := ctx.GetStub().SplitCompositeKey(key)
+
+	if err != nil {
+		return "", fmt.Errorf("Failed to split key %s. %s", key, err.Error())
+	}
+	if len(attributes) == 0 {
+		return "", fmt.Errorf("Key %s has no attributes", key)
+	}
+
+	return attributes[0], nil
+}
+
+// indexedIDs returns the ids pointed to by every index key matching the partial attributes
+func indexedIDs(ctx contractapi.TransactionContextInterface, index string, attributes []string) ([]string, error) {
+	recordIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(index, attributes)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get record iterator. %s", err.Error())
+	}
+
+	defer recordIterator.Close()
+
+	return iteratorIDs(ctx, recordIterator)
+}
+
+// pagedIndexedIDs returns the ids pointed to by one page of the index keys matching the partial attributes, and the metadata holding the next page's bookmark
+func pagedIndexedIDs(ctx contractapi.TransactionContextInterface, index string, attributes []string, pageSize int32, bookmark string) ([]string, *peer.QueryResponseMetadata, error) {
+	recordIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(index, attributes, pageSize, bookmark)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to get record iterator. %s", err.Error())
+	}
+
+	defer recordIterator.Close()
+
+	ids, err := iteratorIDs(ctx, recordIterator)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return ids, metadata, nil
+}
+
+// iteratorIDs returns the ids pointed to by the index keys of an iterator, which are the last attributes of the keys
+func iteratorIDs(ctx contractapi.TransactionContextInterface, recordIterator shim.StateQueryIteratorInterface) ([]string, error) {
+	ids := []string{}
+
+	for recordIterator.HasNext() {
+		record, err := recordIterator.Next()
+
+		if err != nil {
+			return nil, fmt.Errorf("Error. %s", err.Error())
+		}
+
+		_, keyAttributes, err := ctx.GetStub().SplitCompositeKey(record.Key)
+
+		if err != nil || len(keyAttributes) == 0 {
+			return nil, fmt.Errorf("Failed to split key %s", record.Key)
+		}
+
+		ids = append(ids, keyAttributes[len(keyAttributes)-1])
+	}
+
+	return ids, nil
+}
+
+// putIndex points an index key at an entity, or removes it when the entity should no longer be found through the index
+func putIndex(ctx contractapi.TransactionContextInterface, present bool, index string, attributes ...string) error {
+	key, err := stateKey(ctx, index, attributes...)
+
+	if err != nil {
+		return err
+	}
+
+	if present {
+		err = ctx.GetStub().PutState(key, indexValue)
+	} else {
+		err = ctx.GetStub().DelState(key)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+var specimenIndexes = []string{collectionTaxonIndex, collectionCatalogNumberIndex, collectionAccessionNumberIndex}
+
+// specimenIndexAttributes returns the attributes of a specimen's key in an index, or nil if the specimen does not belong in the index.
+// Every specimen is in the collection taxon index, even with a blank taxon, so that it lists every specimen of a collection.
+func specimenIndexAttributes(index string, guid string, specimen *Specimen) []string {
+	switch index {
+	case collectionTaxonIndex:
+		return []string{specimen.Collection, specimen.Taxon, guid}
+	case collectionCatalogNumberIndex:
+		if specimen.CatalogNumber != "" {
+			return []string{specimen.Collection, specimen.CatalogNumber, guid}
+		}
+	case collectionAccessionNumberIndex:
+		if specimen.AccessionNumber != "" {
+			return []string{specimen.Collection, specimen.AccessionNumber, guid}
+		}
+	}
+
+	return nil
+}
+
+// indexSpecimen keeps a specimen's index keys in step with its fields. oldSpecimen is nil for a specimen which has not been indexed yet.
+func indexSpecimen(ctx contractapi.TransactionContextInterface, guid string, oldSpecimen *Specimen, specimen *Specimen) error {
+	for _, index := range specimenIndexes {
+		attributes := specimenIndexAttributes(index, guid, specimen)
+
+		if oldSpecimen != nil {
+			oldAttributes := specimenIndexAttributes(index, guid, oldSpecimen)
+
+			if strings.Join(oldAttributes, "\x00") == strings.Join(attributes, "\x00") {
+				continue
+			}
+
+			if oldAttributes != nil {
+				err := putIndex(ctx, false, index, oldAttributes...)
+
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		if attributes != nil {
+			err := putIndex(ctx, true, index, attributes...)
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// legacyObjectType works out which entity a value stored under a pre-namespacing flat key belongs to
+func legacyObjectType(key string, value []byte) (string, string) {
+	if key == "config" {
+		return configObjectType, ""
+	}
+	if strings.HasPrefix(key, "identity|") {
+		return identityObjectType, strings.TrimPrefix(key, "identity|")
+	}
+	if strings.HasSuffix(key, "|attribution") {
+		return attributionObjectType, strings.TrimSuffix(key, "|attribution")
+	}
+	if strings.HasPrefix(key, "pending") {
+		pendingTransactions := []PendingTransaction{}
+		if json.Unmarshal(value, &pendingTransactions) == nil {
+			return pendingObjectType, strings.TrimPrefix(key, "pending")
+		}
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if json.Unmarshal(value, &fields) != nil {
+		return "", ""
+	}
+
+	if _, ok := fields["membership"]; ok {
+		return userObjectType, key
+	}
+	if _, ok := fields["createSpecimen"]; ok {
+		return collectionObjectType, key
+	}
+	if _, ok := fields["catalogNumber"]; ok {
+		return specimenObjectType, key
+	}
+
+	return "", ""
+}
+
+type MigrationBatch struct {
+	Migrated map[string]int `json:"migrated"`
+	//Flat key the next batch starts from, blank once every flat key has been scanned
+	Bookmark string `json:"bookmark"`
+}
+
+// MigrateKeys rewrites up to batchSize entities stored under flat keys to their namespaced composite keys, starting from the bookmark of the previous batch,
+// and returns a count of the entities moved per object type. Scanning the whole ledger in one transaction would exceed the peer's timeout.
+func (s *SmartContract) MigrateKeys(ctx contractapi.TransactionContextInterface, batchSize int32, bookmark string) (*MigrationBatch, error) {
+	if !isAdmin(ctx) {
+		return nil, fmt.Errorf("Only an identity with the %s attribute may migrate world state", adminAttribute)
+	}
+
+	if batchSize <= 0 {
+		return nil, fmt.Errorf("Batch size must be positive")
+	}
+
+	recordIterator, err := ctx.GetStub().GetStateByRange(bookmark, "")
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get record iterator. %s", err.Error())
+	}
+
+	defer recordIterator.Close()
+
+	migrated := make(map[string]int)
+	result := MigrationBatch{migrated, ""}
+	scanned := int32(0)
+
+	for recordIterator.HasNext() {
+		record, err := recordIterator.Next()
+
+		if err != nil {
+			return nil, fmt.Errorf("Error. %s", err.Error())
+		}
+
+		//Composite keys begin with U+0000 and have already been migrated
+		if strings.HasPrefix(record.Key, "\x00") {
+			continue
+		}
+
+		if scanned == batchSize {
+			result.Bookmark = record.Key
+			break
+		}
+
+		scanned += 1
+
+		objectType, id := legacyObjectType(record.Key, record.Value)
+
+		if objectType == "" {
+			migrated["skipped"] += 1
+			continue
+		}
+
+		err = putState(ctx, objectType, id, record.Value)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+		}
+
+		err = ctx.GetStub().DelState(record.Key)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to delete from world state. %s", err.Error())
+		}
+
+		if objectType == specimenObjectType {
+			specimen := new(Specimen)
+			_ = json.Unmarshal(record.Value, specimen)
+
+			err = indexSpecimen(ctx, id, nil, specimen)
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		migrated[objectType] += 1
+	}
+
+	actor, err := clientIdentityID(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "MigrateKeys", Actor: actor})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// IndexSpecimens rebuilds the index keys of every specimen, for specimens stored before their indexes were kept
+func (s *SmartContract) IndexSpecimens(ctx contractapi.TransactionContextInterface) (int, error) {
+	if !isAdmin(ctx) {
+		return 0, fmt.Errorf("Only an identity with the %s attribute may index specimens", adminAttribute)
+	}
+
+	recordIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(specimenObjectType, []string{})
+
+	if err != nil {
+		return 0, fmt.Errorf("Failed to get record iterator. %s", err.Error())
+	}
+
+	defer recordIterator.Close()
+
+	indexed := 0
+
+	for recordIterator.HasNext() {
+		record, err := recordIterator.Next()
+
+		if err != nil {
+			return 0, fmt.Errorf("Error. %s", err.Error())
+		}
+
+		guid, err := keyID(ctx, record.Key)
+
+		if err != nil {
+			return 0, err
+		}
+
+		specimen := new(Specimen)
+		_ = json.Unmarshal(record.Value, specimen)
+
+		err = indexSpecimen(ctx, guid, nil, specimen)
+
+		if err != nil {
+			return 0, err
+		}
+
+		indexed += 1
+	}
+
+	actor, err := clientIdentityID(ctx)
+
+	if err != nil {
+		return 0, err
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "IndexSpecimens", Actor: actor})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return indexed, nil
+}
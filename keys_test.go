@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/golang/protobuf/ptypes"
+)
+
+// putLegacyState writes raw values in one transaction, as earlier versions of the chaincode stored them
+func (h *contractHarness) putLegacyState(entities map[string]string) {
+	h.clock = h.now()
+	timestamp, _ := ptypes.TimestampProto(h.clock)
+	h.tx = "legacy"
+	h.stub.writes, h.stub.keys = nil, nil
+	h.stub.MockTransactionStart(h.tx)
+	h.stub.TxTimestamp = timestamp
+	for key, value := range entities {
+		h.stub.PutState(key, []byte(value))
+	}
+	if err := h.stub.commit(); err != nil {
+		h.t.Fatal(err)
+	}
+	h.stub.MockTransactionEnd(h.tx)
+}
+
+func TestMigrateKeysInBatches(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.putLegacyState(map[string]string{
+		"9":                   `{"collection":"KU Ornithology","catalogNumber":"9","taxon":"Corvus corax","vandalizedTransactions":[]}`,
+		"olduser":             `{"username":"olduser","membership":{"KU Ornithology":"C"}}`,
+		"pending9":            `[]`,
+		"olduser|attribution": `Created Specimen with GUID 9`,
+	})
+
+	h.fail("MigrateKeys", "1", "")
+
+	h.asAdmin()
+	h.fail("MigrateKeys", "0", "")
+
+	migrated := make(map[string]int)
+	bookmark := ""
+	batches := 0
+
+	for {
+		batch := MigrationBatch{}
+		h.okInto(&batch, "MigrateKeys", "3", bookmark)
+
+		for objectType, count := range batch.Migrated {
+			migrated[objectType] += count
+		}
+
+		batches++
+		bookmark = batch.Bookmark
+
+		if bookmark == "" {
+			break
+		}
+	}
+
+	if batches != 2 {
+		t.Errorf("Migrated in %d batches", batches)
+	}
+
+	for _, objectType := range []string{specimenObjectType, userObjectType, pendingObjectType, attributionObjectType} {
+		if migrated[objectType] != 1 {
+			t.Errorf("Migrated %d %s entities", migrated[objectType], objectType)
+		}
+	}
+
+	h.as("app", nil)
+	specimen := Specimen{}
+	h.okInto(&specimen, "Query", "9", "olduser")
+
+	if specimen.CatalogNumber != "9" {
+		t.Errorf("Migrated specimen has catalog number %q", specimen.CatalogNumber)
+	}
+
+	//Migrated specimens are indexed by collection and taxon
+	h.ok("UpdateTaxonClass", "KU Ornithology", "manager", "Corvus corax", "Corvus corone")
+	h.okInto(&specimen, "Query", "9", "olduser")
+
+	if specimen.Taxon != "Corvus corone" {
+		t.Errorf("Migrated specimen has taxon %q", specimen.Taxon)
+	}
+}
+
+func TestHistoryIncludesVersionsBeforeMigration(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.putLegacyState(map[string]string{
+		"9": `{"collection":"KU Ornithology","catalogNumber":"9","taxon":"Corvus corax","vandalizedTransactions":[]}`,
+	})
+
+	h.asAdmin()
+	h.ok("MigrateKeys", "100", "")
+
+	h.as("app", nil)
+	h.ok("PatchSpecimen", `{"guid":"9","updater":"manager","preparation":"skin"}`)
+
+	entries := []HistoryEntry{}
+	h.okInto(&entries, "GetHistory", "9", "manager")
+
+	if len(entries) != 3 {
+		entriesBytes, _ := json.Marshal(entries)
+		t.Fatalf("History has %d versions: %s", len(entries), entriesBytes)
+	}
+
+	if entries[0].TxID != "legacy" || entries[0].IsDelete || entries[0].Record.CatalogNumber != "9" {
+		t.Errorf("First version is %+v", entries[0])
+	}
+
+	if entries[2].Record.Preparation != "skin" {
+		t.Errorf("Last version has preparation %q", entries[2].Record.Preparation)
+	}
+}
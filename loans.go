@@ -0,0 +1,411 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	loanStatusOpen              = "open"
+	loanStatusPartiallyReturned = "partiallyReturned"
+	loanStatusClosed            = "closed"
+)
+
+type Loan struct {
+	ID          string          `json:"id"`
+	Guid        string          `json:"guid"`
+	Collection  string          `json:"collection"`
+	Parts       string          `json:"parts"`
+	Institution string          `json:"institution"`
+	Contact     string          `json:"contact"`
+	LoanDate    string          `json:"loanDate"`
+	DueDate     string          `json:"dueDate"`
+	Status      string          `json:"status"`
+	Registrar   string          `json:"registrar"`
+	Returns     []LoanReturn    `json:"returns"`
+	Extensions  []LoanExtension `json:"extensions"`
+}
+
+type LoanReturn struct {
+	Parts    string `json:"parts"`
+	Date     string `json:"date"`
+	Receiver string `json:"receiver"`
+	Notes    string `json:"notes"`
+}
+
+type LoanExtension struct {
+	PreviousDueDate string `json:"previousDueDate"`
+	DueDate         string `json:"dueDate"`
+	Extender        string `json:"extender"`
+}
+
+func checkISODate(name string, date string) error {
+	if _, err := time.Parse(isoDateLayout, date); err != nil {
+		return fmt.Errorf("%s %s is not a date in YYYY-MM-DD format", name, date)
+	}
+
+	return nil
+}
+
+func getLoan(ctx contractapi.TransactionContextInterface, loanID string) (*Loan, error) {
+	loanBytes, err := getState(ctx, loanObjectType, loanID)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if loanBytes == nil {
+		return nil, fmt.Errorf("Loan %s does not exist", loanID)
+	}
+
+	loan := new(Loan)
+	_ = json.Unmarshal(loanBytes, loan)
+
+	return loan, nil
+}
+
+func putLoan(ctx contractapi.TransactionContextInterface, loan *Loan) error {
+	err := putIndex(ctx, loan.Status != loanStatusClosed, openLoanIndex, loan.Collection, loan.ID)
+
+	if err != nil {
+		return err
+	}
+
+	err = putIndex(ctx, true, specimenLoanIndex, loan.Guid, loan.ID)
+
+	if err != nil {
+		return err
+	}
+
+	loanBytes, _ := json.Marshal(loan)
+
+	err = putState(ctx, loanObjectType, loan.ID, loanBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// loanAccess loads a loan and checks that the user's role in the loan's collection may register loans
+func loanAccess(ctx contractapi.TransactionContextInterface, loanID string, username string, action string) (*Loan, *User, error) {
+	loan, err := getLoan(ctx, loanID)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	collect, err := getCollection(ctx, loan.Collection)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	role := roleIn(user, loan.Collection)
+
+	if !strings.Contains(collect.RegisterLoan, role) {
+		return nil, nil, fmt.Errorf("%s has role %s but role %s is required to %s", user.Username, role, collect.RegisterLoan, action)
+	}
+
+	return loan, user, nil
+}
+
+func (s *SmartContract) OpenLoan(ctx contractapi.TransactionContextInterface, guid string, username string, parts string, institution string, contact string, loanDate string, dueDate string) (*Loan, error) {
+	specimen, err := getSpecimen(ctx, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	username = user.Username
+
+	collect, err := getCollection(ctx, specimen.Collection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	role := roleIn(user, specimen.Collection)
+
+	if !strings.Contains(collect.RegisterLoan, role) {
+		return nil, fmt.Errorf("%s has role %s but role %s is required to register loans", username, role, collect.RegisterLoan)
+	}
+
+	if parts == "" || institution == "" {
+		return nil, fmt.Errorf("A loan requires the parts loaned and the borrowing institution")
+	}
+	if err := checkISODate("Loan date", loanDate); err != nil {
+		return nil, err
+	}
+	if err := checkISODate("Due date", dueDate); err != nil {
+		return nil, err
+	}
+	if dueDate < loanDate {
+		return nil, fmt.Errorf("Due date %s is before loan date %s", dueDate, loanDate)
+	}
+
+	loan := Loan{ctx.GetStub().GetTxID(), guid, specimen.Collection, parts, institution, contact, loanDate, dueDate, loanStatusOpen, username, []LoanReturn{}, []LoanExtension{}}
+
+	err = putLoan(ctx, &loan)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attributionString := fmt.Sprintf("Opened loan %s for specimen with GUID %s", loan.ID, guid)
+	err = attribute(ctx, username, "OpenLoan", loanObjectType, loan.ID, guid, attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "OpenLoan", ObjectType: loanObjectType, ID: loan.ID, Actor: username, ChangedFields: changedFields(Loan{}, loan), AffectedIDs: []string{guid}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &loan, nil
+}
+
+func (s *SmartContract) ExtendLoan(ctx contractapi.TransactionContextInterface, loanID string, username string, dueDate string) (*Loan, error) {
+	loan, user, err := loanAccess(ctx, loanID, username, "extend loans")
+
+	if err != nil {
+		return nil, err
+	}
+
+	oldLoan := *loan
+
+	if loan.Status == loanStatusClosed {
+		return nil, fmt.Errorf("Loan %s is already closed", loanID)
+	}
+	if err := checkISODate("Due date", dueDate); err != nil {
+		return nil, err
+	}
+	if dueDate <= loan.DueDate {
+		return nil, fmt.Errorf("New due date %s must be after the current due date %s", dueDate, loan.DueDate)
+	}
+
+	loan.Extensions = append(loan.Extensions, LoanExtension{loan.DueDate, dueDate, user.Username})
+	loan.DueDate = dueDate
+
+	err = putLoan(ctx, loan)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attributionString := fmt.Sprintf("Extended loan %s for specimen with GUID %s to %s", loanID, loan.Guid, dueDate)
+	err = attribute(ctx, user.Username, "ExtendLoan", loanObjectType, loanID, loan.Guid, attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "ExtendLoan", ObjectType: loanObjectType, ID: loanID, Actor: user.Username, ChangedFields: changedFields(&oldLoan, loan), AffectedIDs: []string{loan.Guid}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return loan, nil
+}
+
+func (s *SmartContract) ReturnLoanParts(ctx contractapi.TransactionContextInterface, loanID string, username string, parts string, date string, notes string) (*Loan, error) {
+	loan, user, err := loanAccess(ctx, loanID, username, "register loan returns")
+
+	if err != nil {
+		return nil, err
+	}
+
+	oldLoan := *loan
+
+	if loan.Status == loanStatusClosed {
+		return nil, fmt.Errorf("Loan %s is already closed", loanID)
+	}
+	if parts == "" {
+		return nil, fmt.Errorf("A partial return requires the parts returned")
+	}
+	if err := checkISODate("Return date", date); err != nil {
+		return nil, err
+	}
+
+	loan.Returns = append(loan.Returns, LoanReturn{parts, date, user.Username, notes})
+	loan.Status = loanStatusPartiallyReturned
+
+	err = putLoan(ctx, loan)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attributionString := fmt.Sprintf("Registered partial return of loan %s for specimen with GUID %s", loanID, loan.Guid)
+	err = attribute(ctx, user.Username, "ReturnLoanParts", loanObjectType, loanID, loan.Guid, attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "ReturnLoanParts", ObjectType: loanObjectType, ID: loanID, Actor: user.Username, ChangedFields: changedFields(&oldLoan, loan), AffectedIDs: []string{loan.Guid}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return loan, nil
+}
+
+func (s *SmartContract) CloseLoan(ctx contractapi.TransactionContextInterface, loanID string, username string, date string, notes string) (*Loan, error) {
+	loan, user, err := loanAccess(ctx, loanID, username, "close loans")
+
+	if err != nil {
+		return nil, err
+	}
+
+	oldLoan := *loan
+
+	if loan.Status == loanStatusClosed {
+		return nil, fmt.Errorf("Loan %s is already closed", loanID)
+	}
+	if err := checkISODate("Return date", date); err != nil {
+		return nil, err
+	}
+
+	//The closing return covers whatever parts are still out
+	loan.Returns = append(loan.Returns, LoanReturn{"", date, user.Username, notes})
+	loan.Status = loanStatusClosed
+
+	err = putLoan(ctx, loan)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attributionString := fmt.Sprintf("Closed loan %s for specimen with GUID %s", loanID, loan.Guid)
+	err = attribute(ctx, user.Username, "CloseLoan", loanObjectType, loanID, loan.Guid, attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "CloseLoan", ObjectType: loanObjectType, ID: loanID, Actor: user.Username, ChangedFields: changedFields(&oldLoan, loan), AffectedIDs: []string{loan.Guid}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return loan, nil
+}
+
+func (s *SmartContract) QueryLoan(ctx contractapi.TransactionContextInterface, loanID string, username string) (*Loan, error) {
+	loan, err := getLoan(ctx, loanID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = unwithheldQueryPermissions(ctx, loan.Collection, username, loanObjectType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return loan, nil
+}
+
+func loansByIndex(ctx contractapi.TransactionContextInterface, index string, attributes []string) ([]Loan, error) {
+	loanIDs, err := indexedIDs(ctx, index, attributes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := []Loan{}
+
+	for _, loanID := range loanIDs {
+		loan, err := getLoan(ctx, loanID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, *loan)
+	}
+
+	return results, nil
+}
+
+func (s *SmartContract) QueryOpenLoans(ctx contractapi.TransactionContextInterface, collection string, username string) ([]Loan, error) {
+	_, err := unwithheldQueryPermissions(ctx, collection, username, loanObjectType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return loansByIndex(ctx, openLoanIndex, []string{collection})
+}
+
+// QueryOverdueLoans returns the open loans of a collection whose due date is before the date of the transaction
+func (s *SmartContract) QueryOverdueLoans(ctx contractapi.TransactionContextInterface, collection string, username string) ([]Loan, error) {
+	_, err := unwithheldQueryPermissions(ctx, collection, username, loanObjectType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	today, err := txDate(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	loans, err := loansByIndex(ctx, openLoanIndex, []string{collection})
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := []Loan{}
+
+	for _, loan := range loans {
+		if loan.DueDate < today {
+			results = append(results, loan)
+		}
+	}
+
+	return results, nil
+}
+
+func (s *SmartContract) QuerySpecimenLoans(ctx contractapi.TransactionContextInterface, guid string, username string) ([]Loan, error) {
+	specimen, err := getSpecimen(ctx, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = unwithheldQueryPermissions(ctx, specimen.Collection, username, loanObjectType)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return loansByIndex(ctx, specimenLoanIndex, []string{guid})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLoanLifecycle(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.fail("OpenLoan", "0", "public", "left wing", "AMNH", "J Doe", "2020-01-01", "2020-02-01")
+	h.fail("OpenLoan", "0", "manager", "left wing", "AMNH", "J Doe", "2020-02-01", "2020-01-01")
+
+	loan := Loan{}
+	h.okInto(&loan, "OpenLoan", "0", "manager", "left wing", "AMNH", "J Doe", "2020-01-01", "2020-02-01")
+
+	if loan.Status != loanStatusOpen || loan.ID != h.tx {
+		t.Errorf("Opened loan %+v", loan)
+	}
+
+	h.okInto(&loan, "ExtendLoan", loan.ID, "curator", "2020-03-01")
+	h.okInto(&loan, "ReturnLoanParts", loan.ID, "curator", "feathers", "2020-02-01", "")
+
+	if loan.Status != loanStatusPartiallyReturned || loan.DueDate != "2020-03-01" || len(loan.Extensions) != 1 {
+		t.Errorf("Extended and partially returned loan %+v", loan)
+	}
+
+	loans := []Loan{}
+	h.okInto(&loans, "QueryOverdueLoans", "KU Ornithology", "public")
+
+	if len(loans) != 1 {
+		t.Errorf("%d loans are overdue", len(loans))
+	}
+
+	h.okInto(&loan, "CloseLoan", loan.ID, "curator", "2020-02-05", "all back")
+	h.okInto(&loans, "QueryOpenLoans", "KU Ornithology", "public")
+
+	if len(loans) != 0 {
+		t.Errorf("%d loans are open after closing the loan", len(loans))
+	}
+
+	h.okInto(&loans, "QuerySpecimenLoans", "0", "public")
+
+	if len(loans) != 1 || loans[0].Status != loanStatusClosed {
+		t.Errorf("Specimen has loans %+v", loans)
+	}
+}
+
+func TestHiddenLoansFailLikeUnqueryableCollection(t *testing.T) {
+	h := newContractHarness(t)
+
+	loan := Loan{}
+	h.okInto(&loan, "OpenLoan", "0", "manager", "left wing", "AMNH", "J Doe", "2020-01-01", "2020-02-01")
+	h.ok("SetRedactionPolicy", "KU Ornithology", "manager", "SP", "-1", "false", "true", "false")
+
+	for _, query := range [][]string{
+		{"QueryLoan", loan.ID, "public"},
+		{"QueryOpenLoans", "KU Ornithology", "public"},
+		{"QueryOverdueLoans", "KU Ornithology", "public"},
+		{"QuerySpecimenLoans", "0", "public"},
+	} {
+		message := h.fail(query[0], query[1:]...)
+
+		if !strings.Contains(message, "Loans of collection KU Ornithology are withheld from role P") {
+			t.Errorf("%s failed with %q", query[0], message)
+		}
+
+		h.ok(query[0], query[1], "assistant")
+	}
+}
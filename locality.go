@@ -0,0 +1,247 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	//Private data collection defined in collections_config.json, which only the peers of its member organizations hold
+	localityCollection = "sensitiveLocality"
+
+	//Precise localities are passed in the transient map so that they are never written to the public ledger as transaction arguments
+	preciseLocalityTransientKey = "preciseLocality"
+
+	maxCoordinatePrecision = 6
+)
+
+// PreciseLocality is the exact locality of a specimen of a threatened taxon, held in a private data collection.
+// The public specimen holds a generalized locality and the SHA-256 hash of the private record.
+type PreciseLocality struct {
+	Location  string `json:"location"`
+	Latitude  string `json:"latitude"`
+	Longitude string `json:"longitude"`
+	Habitat   string `json:"habitat"`
+	Recorder  string `json:"recorder"`
+	Recorded  string `json:"recorded"`
+}
+
+// roundCoordinate rounds decimal degrees to a number of decimal places, returning "" for a coordinate which is not a number
+func roundCoordinate(coordinate string, decimals int) string {
+	value, err := strconv.ParseFloat(coordinate, 64)
+
+	if err != nil {
+		return ""
+	}
+
+	scale := math.Pow(10, float64(decimals))
+
+	return strconv.FormatFloat(math.Round(value*scale)/scale, 'f', decimals, 64)
+}
+
+// generalizedVersion returns an earlier version of a specimen with the generalized locality of the current version, when the version was written before the
+// current precise locality was withheld and may still hold it. Any other version is returned as it is.
+func generalizedVersion(version *Specimen, current *Specimen) *Specimen {
+	if version == nil || current.LocalityHash == "" || version.LocalityHash == current.LocalityHash {
+		return version
+	}
+
+	generalized := *version
+	generalized.Location, generalized.Latitude, generalized.Longitude, generalized.Habitat = current.Location, current.Latitude, current.Longitude, current.Habitat
+	generalized.LocalityHash = current.LocalityHash
+
+	return &generalized
+}
+
+func localityHash(localityBytes []byte) string {
+	hash := sha256.Sum256(localityBytes)
+
+	return hex.EncodeToString(hash[:])
+}
+
+// georeferenceAccess returns the specimen and the acting user after checking that the user's role may change or read its geolocation info
+func georeferenceAccess(ctx contractapi.TransactionContextInterface, guid string, username string) (*Specimen, *User, error) {
+	specimen, err := getSpecimen(ctx, guid)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	collect, err := getCollection(ctx, specimen.Collection)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	role := roleIn(user, specimen.Collection)
+
+	if !strings.Contains(collect.Georeference, role) {
+		return nil, nil, fmt.Errorf("%s has role %s but role %s is required to access precise locality", user.Username, role, collect.Georeference)
+	}
+
+	return specimen, user, nil
+}
+
+// SetPreciseLocality moves a specimen's precise locality into the private data collection, replacing it on the public specimen with a generalized locality.
+// The precise locality is read from the transient map as a JSON PreciseLocality.
+func (s *SmartContract) SetPreciseLocality(ctx contractapi.TransactionContextInterface, guid string, username string, generalizedLocation string, generalizedHabitat string, coordinatePrecision int) (*Specimen, error) {
+	oldSpecimen, user, err := georeferenceAccess(ctx, guid, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	username = user.Username
+
+	if coordinatePrecision < 0 || coordinatePrecision > maxCoordinatePrecision {
+		return nil, fmt.Errorf("Coordinate precision must be between 0 and %d decimal places", maxCoordinatePrecision)
+	}
+
+	transient, err := ctx.GetStub().GetTransient()
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get transient map. %s", err.Error())
+	}
+
+	transientBytes, ok := transient[preciseLocalityTransientKey]
+
+	if !ok {
+		return nil, fmt.Errorf("The precise locality must be passed in the transient map under %s", preciseLocalityTransientKey)
+	}
+
+	locality := new(PreciseLocality)
+
+	err = json.Unmarshal(transientBytes, locality)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal precise locality. %s", err.Error())
+	}
+
+	errors := []FieldError{}
+
+	if locality.Latitude != "" {
+		if message := checkCoordinate(locality.Latitude, 90); message != "" {
+			errors = append(errors, FieldError{"latitude", locality.Latitude, message})
+		}
+	}
+	if locality.Longitude != "" {
+		if message := checkCoordinate(locality.Longitude, 180); message != "" {
+			errors = append(errors, FieldError{"longitude", locality.Longitude, message})
+		}
+	}
+	if locality.Latitude == "" && locality.Longitude != "" {
+		errors = append(errors, FieldError{"latitude", "", "is required when longitude is given"})
+	}
+	if locality.Longitude == "" && locality.Latitude != "" {
+		errors = append(errors, FieldError{"longitude", "", "is required when latitude is given"})
+	}
+	if len(errors) > 0 {
+		return nil, &ValidationError{guid, errors}
+	}
+
+	recorded, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	locality.Recorder = username
+	locality.Recorded = recorded.Format(time.RFC3339)
+
+	localityBytes, _ := json.Marshal(locality)
+	key, err := stateKey(ctx, preciseLocalityObjectType, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = ctx.GetStub().PutPrivateData(localityCollection, key, localityBytes)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to private data collection %s. %s", localityCollection, err.Error())
+	}
+
+	specimen := *oldSpecimen
+	specimen.Location = generalizedLocation
+	specimen.Habitat = generalizedHabitat
+	specimen.Latitude = roundCoordinate(locality.Latitude, coordinatePrecision)
+	specimen.Longitude = roundCoordinate(locality.Longitude, coordinatePrecision)
+	specimen.LocalityHash = localityHash(localityBytes)
+	specimen.Updater = username
+
+	attributionString := fmt.Sprintf("Set precise locality of specimen with GUID %s", guid)
+	err = attribute(ctx, username, "SetPreciseLocality", specimenObjectType, guid, guid, attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	specimenBytes, _ := json.Marshal(specimen)
+
+	err = putState(ctx, specimenObjectType, guid, specimenBytes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "SetPreciseLocality", ObjectType: specimenObjectType, ID: guid, Actor: username, ChangedFields: changedFields(oldSpecimen, &specimen)})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newQueryPermissions(user).redact(ctx, &specimen)
+}
+
+// QueryPreciseLocality returns the precise locality of a specimen to a user whose role may change its geolocation info.
+// It must be evaluated on a peer of an organization which is a member of the private data collection.
+func (s *SmartContract) QueryPreciseLocality(ctx contractapi.TransactionContextInterface, guid string, username string) (*PreciseLocality, error) {
+	specimen, _, err := georeferenceAccess(ctx, guid, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if specimen.LocalityHash == "" {
+		return nil, fmt.Errorf("%s does not have a precise locality", guid)
+	}
+
+	key, err := stateKey(ctx, preciseLocalityObjectType, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	localityBytes, err := ctx.GetStub().GetPrivateData(localityCollection, key)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from private data collection %s. %s", localityCollection, err.Error())
+	}
+
+	if localityBytes == nil {
+		return nil, fmt.Errorf("The precise locality of %s is not held by this peer", guid)
+	}
+
+	if localityHash(localityBytes) != specimen.LocalityHash {
+		return nil, fmt.Errorf("The precise locality of %s held by this peer does not match its hash", guid)
+	}
+
+	locality := new(PreciseLocality)
+	_ = json.Unmarshal(localityBytes, locality)
+
+	return locality, nil
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+)
+
+var suvaPoint = map[string][]byte{preciseLocalityTransientKey: []byte(`{"location":"Suva Point","latitude":"-18.1483325958","longitude":"178.3984985352","habitat":"Barrier reef"}`)}
+
+func TestSetPreciseLocality(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.fail("SetPreciseLocality", "0", "assistant", "Fiji", "Reef", "1")
+
+	_, message := h.invoke(suvaPoint, "SetPreciseLocality", "0", "student", "Fiji", "Reef", "1")
+
+	if message == "" {
+		t.Errorf("Student withheld a precise locality")
+	}
+
+	specimen := Specimen{}
+	h.okTransient(suvaPoint, "SetPreciseLocality", "0", "assistant", "Fiji", "Reef", "1")
+	h.okInto(&specimen, "Query", "0", "public")
+
+	if specimen.Location != "Fiji" || specimen.Habitat != "Reef" || specimen.Latitude != "-18.1" || specimen.Longitude != "178.4" || specimen.LocalityHash == "" {
+		t.Errorf("Withholding the precise locality left specimen %+v", specimen)
+	}
+
+	locality := PreciseLocality{}
+	h.okInto(&locality, "QueryPreciseLocality", "0", "assistant")
+
+	if locality.Location != "Suva Point" || locality.Recorder != "assistant" {
+		t.Errorf("Precise locality is %+v", locality)
+	}
+
+	h.fail("QueryPreciseLocality", "0", "student")
+	h.fail("PatchSpecimen", `{"guid":"0","updater":"manager","latitude":"1","longitude":"1"}`)
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"manager","preparation":"skin"}`)
+}
+
+func TestWithheldLocalityStaysOutOfHistoryAndExport(t *testing.T) {
+	h := newContractHarness(t)
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"manager","location":"Suva Point","habitat":"Barrier reef"}`)
+	h.okTransient(suvaPoint, "SetPreciseLocality", "0", "assistant", "Fiji", "Reef", "1")
+
+	entries := []HistoryEntry{}
+	h.okInto(&entries, "GetHistory", "0", "manager")
+
+	if len(entries) != 3 {
+		t.Fatalf("History has %d versions", len(entries))
+	}
+
+	for _, entry := range entries {
+		if entry.Record.Location != "Fiji" || entry.Record.Habitat != "Reef" || entry.Record.Latitude != "-18.1" || entry.Record.Longitude != "178.4" {
+			t.Errorf("Version written by %s shows locality %s, %s (%s, %s)", entry.TxID, entry.Record.Location, entry.Record.Habitat, entry.Record.Latitude, entry.Record.Longitude)
+		}
+	}
+
+	changes := []FieldChange{}
+	h.okInto(&changes, "DiffSpecimenVersions", "0", "manager", entries[0].TxID, entries[1].TxID)
+
+	for _, change := range changes {
+		if change.Field == "location" || change.Field == "habitat" {
+			t.Errorf("Diff shows %+v", change)
+		}
+	}
+
+	page := OccurrencePage{}
+	h.okInto(&page, "ExportDarwinCore", "KU Ornithology", "public", "10", "")
+
+	if len(page.Records) != 1 || page.Records[0].Locality != "Fiji" || page.Records[0].InformationWithheld == "" {
+		t.Errorf("Exported %+v", page.Records)
+	}
+}
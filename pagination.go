@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+type QueryResultPage struct {
+	Records      []QueryResult `json:"records"`
+	FetchedCount int32         `json:"fetchedCount"`
+	Bookmark     string        `json:"bookmark"`
+}
+
+// QueryAllSpecimensWithPagination may return fewer records than were fetched when some belong to collections the caller may not query
+func (s *SmartContract) QueryAllSpecimensWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*QueryResultPage, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("Page size must be positive")
+	}
+
+	permissions, err := callerPermissions(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	recordIterator, metadata, err := ctx.GetStub().GetStateByPartialCompositeKeyWithPagination(specimenObjectType, []string{}, pageSize, bookmark)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get record iterator. %s", err.Error())
+	}
+
+	defer recordIterator.Close()
+
+	results, err := authorizedSpecimens(ctx, recordIterator, permissions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &QueryResultPage{results, metadata.FetchedRecordsCount, metadata.Bookmark}, nil
+}
+
+// CouchQueryWithPagination may return fewer records than were fetched when some belong to collections the caller may not query
+func (s *SmartContract) CouchQueryWithPagination(ctx contractapi.TransactionContextInterface, queryString string, pageSize int32, bookmark string) (*QueryResultPage, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("Page size must be positive")
+	}
+
+	permissions, err := callerPermissions(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	recordIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookmark)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get record iterator from query string. %s", err.Error())
+	}
+
+	defer recordIterator.Close()
+
+	results, err := authorizedSpecimens(ctx, recordIterator, permissions)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &QueryResultPage{results, metadata.FetchedRecordsCount, metadata.Bookmark}, nil
+}
+
+// AuthorizedCouchQueryWithPagination may return fewer records than were fetched when some belong to collections the user may not query
+func (s *SmartContract) AuthorizedCouchQueryWithPagination(ctx contractapi.TransactionContextInterface, queryString string, username string, pageSize int32, bookmark string) (*QueryResultPage, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("Page size must be positive")
+	}
+
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	recordIterator, metadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookmark)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get record iterator from query string. %s", err.Error())
+	}
+
+	defer recordIterator.Close()
+
+	results, err := authorizedSpecimens(ctx, recordIterator, newQueryPermissions(user))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &QueryResultPage{results, metadata.FetchedRecordsCount, metadata.Bookmark}, nil
+}
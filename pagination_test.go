@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+// queryAllPages fetches every page of a paginated query, whose arguments are followed by the page size and bookmark
+func queryAllPages(h *contractHarness, function string, pageSize int32, args ...string) ([]QueryResult, int) {
+	h.t.Helper()
+	results := []QueryResult{}
+	bookmark := ""
+	pages := 0
+
+	for {
+		page := QueryResultPage{}
+		h.okInto(&page, function, append(args, fmt.Sprint(pageSize), bookmark)...)
+		results = append(results, page.Records...)
+		bookmark = page.Bookmark
+		pages++
+
+		if page.FetchedCount < pageSize {
+			return results, pages
+		}
+	}
+}
+
+func TestPaginatedQueriesLeaveOutCollectionsCallerMayNotQuery(t *testing.T) {
+	h := newContractHarness(t)
+	registerHerpetology(h)
+	h.ok("CreateSpecimen", `{"guid":"1","updater":"manager","collection":"KU Ornithology"}`)
+	h.ok("CreateSpecimen", `{"guid":"h2","updater":"curator","collection":"KU Herpetology"}`)
+
+	h.fail("QueryAllSpecimensWithPagination", "0", "")
+
+	results, pages := queryAllPages(h, "QueryAllSpecimensWithPagination", 2)
+
+	if guids := resultGuids(results); !equalGuids(guids, "0", "1") || pages != 3 {
+		t.Errorf("Unlinked identity queried %v in %d pages", guids, pages)
+	}
+
+	results, _ = queryAllPages(h, "CouchQueryWithPagination", 2, `{"selector":{}}`)
+
+	if guids := resultGuids(results); !equalGuids(guids, "0", "1") {
+		t.Errorf("Unlinked identity queried %v", guids)
+	}
+
+	linkAs(h, "curator")
+	results, _ = queryAllPages(h, "QueryAllSpecimensWithPagination", 2)
+
+	if guids := resultGuids(results); !equalGuids(guids, "0", "1", "h1", "h2") {
+		t.Errorf("Curator queried %v", guids)
+	}
+
+	results, _ = queryAllPages(h, "CouchQueryWithPagination", 3, `{"selector":{}}`)
+
+	if guids := resultGuids(results); !equalGuids(guids, "0", "1", "h1", "h2") {
+		t.Errorf("Curator queried %v", guids)
+	}
+}
+
+func TestAuthorizedCouchQueryWithPaginationChecksUsersRole(t *testing.T) {
+	h := newContractHarness(t)
+	registerHerpetology(h)
+
+	results, _ := queryAllPages(h, "AuthorizedCouchQueryWithPagination", 1, `{"selector":{}}`, "student")
+
+	if guids := resultGuids(results); !equalGuids(guids, "0") {
+		t.Errorf("Student queried %v", guids)
+	}
+
+	results, _ = queryAllPages(h, "AuthorizedCouchQueryWithPagination", 1, `{"selector":{}}`, "curator")
+
+	if guids := resultGuids(results); !equalGuids(guids, "0", "h1") {
+		t.Errorf("Curator queried %v", guids)
+	}
+}
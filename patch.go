@@ -0,0 +1,375 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// SpecimenPatch changes only the specimen fields it contains. A field which is absent (or null) is left as it is, while a field set to "" is cleared.
+// Condition and notes are append-only lists, so a non-empty condition or notes entry is appended rather than replacing the list.
+type SpecimenPatch struct {
+	Guid            string  `json:"guid"`
+	Updater         string  `json:"updater"`
+	Collection      *string `json:"collection,omitempty"`
+	CatalogNumber   *string `json:"catalogNumber,omitempty"`
+	AccessionNumber *string `json:"accessionNumber,omitempty"`
+	CatalogDate     *string `json:"catalogDate,omitempty"`
+	Cataloger       *string `json:"cataloger,omitempty"`
+	Taxon           *string `json:"taxon,omitempty"`
+	Determiner      *string `json:"determiner,omitempty"`
+	DetermineDate   *string `json:"determineDate,omitempty"`
+	FieldNumber     *string `json:"fieldNumber,omitempty"`
+	FieldDate       *string `json:"fieldDate,omitempty"`
+	Collector       *string `json:"collector,omitempty"`
+	Location        *string `json:"location,omitempty"`
+	Latitude        *string `json:"latitude,omitempty"`
+	Longitude       *string `json:"longitude,omitempty"`
+	Habitat         *string `json:"habitat,omitempty"`
+	Preparation     *string `json:"preparation,omitempty"`
+	Condition       string  `json:"condition"`
+	ConditionDate   string  `json:"conditionDate"`
+	Notes           string  `json:"notes"`
+	Image           *string `json:"image,omitempty"`
+
+	//Details of a new determination, recorded when the patch changes the taxon, determiner or determineDate
+	TaxonQualifier          string `json:"taxonQualifier,omitempty"`
+	DeterminationMethod     string `json:"determinationMethod,omitempty"`
+	DeterminationRemarks    string `json:"determinationRemarks,omitempty"`
+	DeterminationConfidence string `json:"determinationConfidence,omitempty"`
+}
+
+// optional treats a blank positional parameter as absent, which is how Update has always read its parameters
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
+
+func setField(field *string, value *string) {
+	if value != nil {
+		*field = *value
+	}
+}
+
+// conditionDate returns the date of the condition entry the patch appends, or "" if it appends none
+func (patch *SpecimenPatch) conditionDate() string {
+	if patch.Condition == "" {
+		return ""
+	}
+
+	return patch.ConditionDate
+}
+
+func (patch *SpecimenPatch) applyTo(specimen *Specimen) {
+	setField(&specimen.Collection, patch.Collection)
+	setField(&specimen.CatalogNumber, patch.CatalogNumber)
+	setField(&specimen.AccessionNumber, patch.AccessionNumber)
+	setField(&specimen.CatalogDate, patch.CatalogDate)
+	setField(&specimen.Cataloger, patch.Cataloger)
+	setField(&specimen.Taxon, patch.Taxon)
+	setField(&specimen.Determiner, patch.Determiner)
+	setField(&specimen.DetermineDate, patch.DetermineDate)
+	setField(&specimen.FieldNumber, patch.FieldNumber)
+	setField(&specimen.FieldDate, patch.FieldDate)
+	setField(&specimen.Collector, patch.Collector)
+	setField(&specimen.Location, patch.Location)
+	setField(&specimen.Latitude, patch.Latitude)
+	setField(&specimen.Longitude, patch.Longitude)
+	setField(&specimen.Habitat, patch.Habitat)
+	setField(&specimen.Preparation, patch.Preparation)
+	setField(&specimen.Image, patch.Image)
+
+	if patch.Condition != "" {
+		specimen.Condition = specimen.Condition + patch.Condition + " " + patch.ConditionDate + "\n"
+	}
+	if patch.Notes != "" {
+		specimen.Notes = specimen.Notes + patch.Notes + "\n"
+	}
+}
+
+func parsePatch(specimenPatch string) (*SpecimenPatch, error) {
+	patch := new(SpecimenPatch)
+	err := json.Unmarshal([]byte(specimenPatch), patch)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to unmarshal specimen patch. %s", err.Error())
+	}
+
+	if patch.Guid == "" {
+		return nil, fmt.Errorf("A specimen patch requires a guid")
+	}
+
+	return patch, nil
+}
+
+// createAccess checks that a specimen may be created and is valid, resolving its updater to the acting user.
+// It returns the specimen's collection and the updater's role in it.
+func createAccess(ctx contractapi.TransactionContextInterface, guid string, specimen *Specimen, conditionDate string) (*Collection, string, error) {
+	checkExistence, err := getState(ctx, specimenObjectType, guid)
+
+	if err != nil {
+		return nil, "", fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if checkExistence != nil {
+		return nil, "", fmt.Errorf("%s already exists", guid)
+	}
+
+	user, err := getUser(ctx, specimen.Updater)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	specimen.Updater = user.Username
+
+	collect, err := getCollection(ctx, specimen.Collection)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	role := roleIn(user, specimen.Collection)
+
+	if !strings.Contains(collect.CreateSpecimen, role) {
+		return nil, "", fmt.Errorf("%s has role %s but role %s is required to create specimen", specimen.Updater, role, collect.CreateSpecimen)
+	}
+
+	err = validateSpecimen(ctx, guid, collect, nil, specimen, conditionDate)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return collect, role, nil
+}
+
+// putNewSpecimen stores a specimen which createAccess has allowed, along with its first determination, attribution and index keys
+func putNewSpecimen(ctx contractapi.TransactionContextInterface, action string, guid string, specimen *Specimen) error {
+	if specimen.Taxon != "" || specimen.Determiner != "" {
+		_, err := recordDetermination(ctx, guid, &Specimen{}, Determination{specimen.Taxon, "", specimen.Determiner, specimen.DetermineDate, "", "", "", true, specimen.Updater, "", ""})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	attributionString := fmt.Sprintf("Created Specimen with GUID %s", guid)
+	err := attribute(ctx, specimen.Updater, action, specimenObjectType, guid, guid, attributionString)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	err = indexSpecimen(ctx, guid, nil, specimen)
+
+	if err != nil {
+		return err
+	}
+
+	specimenBytes, _ := json.Marshal(specimen)
+
+	return putState(ctx, specimenObjectType, guid, specimenBytes)
+}
+
+// create stores a new specimen after checking that its updater may create specimens in its collection.
+// It returns the specimen as the updater may see it under the collection's redaction policy.
+func (s *SmartContract) create(ctx contractapi.TransactionContextInterface, action string, guid string, specimen Specimen, conditionDate string) (*Specimen, error) {
+	collect, role, err := createAccess(ctx, guid, &specimen, conditionDate)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = putNewSpecimen(ctx, action, guid, &specimen)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: action, ObjectType: specimenObjectType, ID: guid, Actor: specimen.Updater, ChangedFields: changedFields(Specimen{}, specimen)})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return redact(collect, role, &specimen), nil
+}
+
+// patchSpecimen applies a patch to an existing specimen after checking that the updater's role permits changing every field the patch changes.
+// It returns the specimen as the updater may see it under the collection's redaction policy, and the specimen's determinations when the patch
+// recorded a new one, or nil when it did not.
+func (s *SmartContract) patchSpecimen(ctx contractapi.TransactionContextInterface, action string, patch *SpecimenPatch) (*Specimen, []Determination, error) {
+	guid := patch.Guid
+	oldSpecimen, err := getSpecimen(ctx, guid)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := getUser(ctx, patch.Updater)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	updater := user.Username
+
+	specimen := *oldSpecimen
+	patch.applyTo(&specimen)
+
+	if specimen.Collection != oldSpecimen.Collection {
+		return nil, nil, fmt.Errorf("collection %s does not match existing specimen collection %s", specimen.Collection, oldSpecimen.Collection)
+	}
+
+	collect, err := getCollection(ctx, specimen.Collection)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	role := roleIn(user, specimen.Collection)
+
+	if specimen.CatalogNumber != oldSpecimen.CatalogNumber || specimen.AccessionNumber != oldSpecimen.AccessionNumber || specimen.CatalogDate != oldSpecimen.CatalogDate || specimen.Cataloger != oldSpecimen.Cataloger || specimen.FieldNumber != oldSpecimen.FieldNumber || specimen.FieldDate != oldSpecimen.FieldDate || specimen.Collector != oldSpecimen.Collector {
+		if !strings.Contains(collect.PrimaryUpdate, role) {
+			return nil, nil, fmt.Errorf("%s has role %s but role %s is required to update primary info", updater, role, collect.PrimaryUpdate)
+		}
+	}
+
+	if specimen.Location != oldSpecimen.Location || specimen.Latitude != oldSpecimen.Latitude || specimen.Longitude != oldSpecimen.Longitude || specimen.Habitat != oldSpecimen.Habitat {
+		if !strings.Contains(collect.Georeference, role) {
+			return nil, nil, fmt.Errorf("%s has role %s but role %s is required to update geolocation info", updater, role, collect.Georeference)
+		}
+	}
+
+	//Patching would publish the precise locality which SetPreciseLocality withheld
+	if oldSpecimen.LocalityHash != "" && (specimen.Location != oldSpecimen.Location || specimen.Latitude != oldSpecimen.Latitude || specimen.Longitude != oldSpecimen.Longitude || specimen.Habitat != oldSpecimen.Habitat) {
+		return nil, nil, fmt.Errorf("%s has a precise locality withheld in a private data collection. Use SetPreciseLocality to change its locality", guid)
+	}
+
+	if specimen.Preparation != oldSpecimen.Preparation || specimen.Condition != oldSpecimen.Condition || specimen.Notes != oldSpecimen.Notes {
+		if !strings.Contains(collect.SecondaryUpdate, role) {
+			return nil, nil, fmt.Errorf("%s has role %s but role %s is required to update secondary info", updater, role, collect.SecondaryUpdate)
+		}
+	}
+
+	if specimen.Taxon != oldSpecimen.Taxon || specimen.Determiner != oldSpecimen.Determiner || specimen.DetermineDate != oldSpecimen.DetermineDate {
+		if !strings.Contains(collect.TaxonName, role) {
+			return nil, nil, fmt.Errorf("%s has role %s but role %s is required to update taxon name", updater, role, collect.TaxonName)
+		}
+	}
+
+	if specimen.Image != oldSpecimen.Image {
+		if !strings.Contains(collect.LinkImages, role) {
+			return nil, nil, fmt.Errorf("%s has role %s but role %s is required to link images", updater, role, collect.LinkImages)
+		}
+	}
+
+	//Check if an actual change was made
+	if cmp.Equal(specimen, *oldSpecimen) {
+		return nil, nil, fmt.Errorf("Updated specimen is equivalent to old specimen. Operation aborted to conserve blockchain resources")
+	}
+
+	err = validateSpecimen(ctx, guid, collect, oldSpecimen, &specimen, patch.conditionDate())
+
+	if err != nil {
+		return nil, nil, err
+	}
+	specimen.Updater = updater
+
+	var determinations []Determination
+
+	if specimen.Taxon != oldSpecimen.Taxon || specimen.Determiner != oldSpecimen.Determiner || specimen.DetermineDate != oldSpecimen.DetermineDate {
+		determinations, err = recordDetermination(ctx, guid, oldSpecimen, Determination{specimen.Taxon, patch.TaxonQualifier, specimen.Determiner, specimen.DetermineDate, patch.DeterminationMethod, patch.DeterminationRemarks, patch.DeterminationConfidence, true, updater, "", ""})
+
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	attributionString := fmt.Sprintf("Updated Specimen with GUID %s", guid)
+	err = attribute(ctx, updater, action, specimenObjectType, guid, guid, attributionString)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	err = indexSpecimen(ctx, guid, oldSpecimen, &specimen)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	specimenBytes, _ := json.Marshal(specimen)
+
+	err = putState(ctx, specimenObjectType, guid, specimenBytes)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: action, ObjectType: specimenObjectType, ID: guid, Actor: updater, ChangedFields: changedFields(oldSpecimen, &specimen)})
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return redact(collect, role, &specimen), determinations, nil
+}
+
+func (s *SmartContract) CreateSpecimen(ctx contractapi.TransactionContextInterface, specimenPatch string) (*Specimen, error) {
+	patch, err := parsePatch(specimenPatch)
+
+	if err != nil {
+		return nil, err
+	}
+
+	specimen := Specimen{Updater: patch.Updater, VandalizedTransactions: []string{}}
+	patch.applyTo(&specimen)
+
+	return s.create(ctx, "CreateSpecimen", patch.Guid, specimen, patch.conditionDate())
+}
+
+func (s *SmartContract) PatchSpecimen(ctx contractapi.TransactionContextInterface, specimenPatch string) (*Specimen, error) {
+	patch, err := parsePatch(specimenPatch)
+
+	if err != nil {
+		return nil, err
+	}
+
+	specimen, _, err := s.patchSpecimen(ctx, "PatchSpecimen", patch)
+
+	return specimen, err
+}
+
+// SuggestPatch queues a patch for approval, returning the PendingTransaction which ApproveTransaction and DenyTransaction refer to by id
+func (s *SmartContract) SuggestPatch(ctx contractapi.TransactionContextInterface, specimenPatch string, reason string) (*PendingTransaction, error) {
+	patch, err := parsePatch(specimenPatch)
+
+	if err != nil {
+		return nil, err
+	}
+
+	collection := ""
+
+	if patch.Collection != nil {
+		collection = *patch.Collection
+	}
+
+	_, updater, err := suggestionAccess(ctx, patch.Guid, collection, patch.Updater)
+
+	if err != nil {
+		return nil, err
+	}
+
+	patch.Updater = updater
+	patchBytes, _ := json.Marshal(patch)
+
+	return addPendingTransaction(ctx, "SuggestPatch", patch.Guid, updater, "Patch", []string{string(patchBytes)}, reason)
+}
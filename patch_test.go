@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPatchSpecimenChangesOnlyFieldsItContains(t *testing.T) {
+	h := newContractHarness(t)
+
+	specimen := Specimen{}
+	h.okInto(&specimen, "PatchSpecimen", `{"guid":"0","updater":"manager","preparation":"skin","fieldNumber":"","notes":"first"}`)
+
+	if specimen.Preparation != "skin" || specimen.FieldNumber != "" || specimen.Taxon != "Pygoplites diacanthus" || specimen.Notes != "first\n" {
+		t.Errorf("Patched specimen is %+v", specimen)
+	}
+
+	h.okInto(&specimen, "PatchSpecimen", `{"guid":"0","updater":"manager","preparation":null,"notes":"second"}`)
+
+	if specimen.Preparation != "skin" || specimen.Notes != "first\nsecond\n" {
+		t.Errorf("Patched specimen is %+v", specimen)
+	}
+
+	message := h.fail("PatchSpecimen", `{"guid":"0","updater":"student","preparation":"skeleton"}`)
+
+	if !strings.Contains(message, "required to update secondary info") {
+		t.Errorf("Student patching secondary info failed with %q", message)
+	}
+
+	h.fail("PatchSpecimen", `{"guid":"0","updater":"manager","preparation":"skin"}`)
+	h.fail("PatchSpecimen", `{"updater":"manager","preparation":"skin"}`)
+}
+
+func TestSpecimenWritesCreditTheirTransaction(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.ok("CreateSpecimen", `{"guid":"1","updater":"manager","collection":"KU Ornithology"}`)
+	h.ok("PatchSpecimen", `{"guid":"1","updater":"manager","preparation":"skin"}`)
+	h.ok("Update", "1", "KU Ornithology", "manager", "", "", "", "", "", "", "", "", "", "", "", "", "", "skeleton", "", "", "", "", "")
+
+	pending := PendingTransaction{}
+	h.okInto(&pending, "SuggestPatch", `{"guid":"1","updater":"student","notes":"wing damaged"}`, "seen in drawer")
+	h.ok("ApproveTransaction", "1", "manager", pending.ID)
+
+	contributors := []Contributor{}
+	h.okInto(&contributors, "QuerySpecimenContributors", "1", "manager")
+
+	actions := map[string]int{}
+	for _, contributor := range contributors {
+		for action, count := range contributor.Actions {
+			actions[action] += count
+		}
+	}
+
+	for _, action := range []string{"CreateSpecimen", "PatchSpecimen", "Update", "ApproveTransaction"} {
+		if actions[action] == 0 {
+			t.Errorf("No contribution was credited to %s. Contributions are %v", action, actions)
+		}
+	}
+}
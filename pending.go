@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	pendingStatusPending   = "pending"
+	pendingStatusApproved  = "approved"
+	pendingStatusDenied    = "denied"
+	pendingStatusWithdrawn = "withdrawn"
+)
+
+// getPendingTransactions returns every suggestion ever made for a specimen, including those already resolved.
+// Suggestions made before they were given ids are identified by their position, which stays stable now that resolved suggestions are kept.
+func getPendingTransactions(ctx contractapi.TransactionContextInterface, guid string) ([]PendingTransaction, error) {
+	transactionsBytes, err := getState(ctx, pendingObjectType, guid)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	transactions := []PendingTransaction{}
+
+	if transactionsBytes != nil {
+		err = json.Unmarshal(transactionsBytes, &transactions)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to unmarshal list of pending transactions from bytes. %s", err.Error())
+		}
+	}
+
+	for i := range transactions {
+		if transactions[i].ID == "" {
+			transactions[i].ID = fmt.Sprintf("legacy-%d", i)
+		}
+		if transactions[i].Status == "" {
+			transactions[i].Status = pendingStatusPending
+		}
+	}
+
+	return transactions, nil
+}
+
+// pendingTransactionIndex finds a suggestion by id and checks that it is still waiting to be resolved
+func pendingTransactionIndex(transactions []PendingTransaction, guid string, transactionID string) (int, error) {
+	for i, transaction := range transactions {
+		if transaction.ID != transactionID {
+			continue
+		}
+
+		if transaction.Status != pendingStatusPending {
+			return -1, fmt.Errorf("Pending transaction %s for %s is already %s", transactionID, guid, transaction.Status)
+		}
+
+		return i, nil
+	}
+
+	return -1, fmt.Errorf("Pending transaction %s for %s does not exist", transactionID, guid)
+}
+
+// resolvePendingTransaction records how a suggestion was resolved, keeping it in the specimen's list for audit
+func resolvePendingTransaction(ctx contractapi.TransactionContextInterface, guid string, transactions []PendingTransaction, index int, status string, username string) error {
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	transactions[index].Status = status
+	transactions[index].Resolver = username
+	transactions[index].Resolved = now.Format(time.RFC3339)
+
+	transactionsBytes, _ := json.Marshal(transactions)
+	err = putState(ctx, pendingObjectType, guid, transactionsBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// suggestionAccess checks that the user may suggest changes to a specimen, returning the specimen's collection and the user's resolved username
+func suggestionAccess(ctx contractapi.TransactionContextInterface, guid string, collection string, updater string) (string, string, error) {
+	checkExistence, err := getState(ctx, specimenObjectType, guid)
+
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if checkExistence == nil {
+		return "", "", fmt.Errorf("%s does not exists", guid)
+	}
+
+	user, err := getUser(ctx, updater)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	updater = user.Username
+
+	if collection == "" {
+		specimen := new(Specimen)
+		_ = json.Unmarshal(checkExistence, specimen)
+		collection = specimen.Collection
+	}
+
+	checkCollection, err := getState(ctx, collectionObjectType, collection)
+
+	if err != nil {
+		return "", "", fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+	if checkCollection == nil {
+		return "", "", fmt.Errorf("%s does not exists", collection)
+	}
+
+	collect := new(Collection)
+	_ = json.Unmarshal(checkCollection, collect)
+
+	role := roleIn(user, collection)
+
+	if !strings.Contains(collect.FlagError, role) {
+		return "", "", fmt.Errorf("%s has role %s but role %s is required to suggest updates", updater, role, collect.FlagError)
+	}
+
+	return collection, updater, nil
+}
+
+// addPendingTransaction appends a suggestion to a specimen's list of pending transactions, identified by the id of the suggesting transaction
+func addPendingTransaction(ctx contractapi.TransactionContextInterface, action string, guid string, updater string, transaction string, arguments []string, reason string) (*PendingTransaction, error) {
+	pendingTransactions, err := getPendingTransactions(ctx, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	transactionID := ctx.GetStub().GetTxID()
+	pendingTransaction := PendingTransaction{transactionID, transaction, arguments, updater, reason, now.Format(time.RFC3339), pendingStatusPending, "", ""}
+
+	pendingTransactions = append(pendingTransactions, pendingTransaction)
+
+	pendingTransactionsBytes, _ := json.Marshal(pendingTransactions)
+
+	attributionString := fmt.Sprintf("Suggested update to specimen with GUID %s", guid)
+	err = attribute(ctx, updater, action, pendingObjectType, guid, guid, attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	err = putState(ctx, pendingObjectType, guid, pendingTransactionsBytes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: action, ObjectType: pendingObjectType, ID: guid, Actor: updater, ChangedFields: []string{"pendingTransactions"}, AffectedIDs: []string{transactionID}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &pendingTransaction, nil
+}
+
+// WithdrawTransaction lets the user who suggested a change take it back before it is approved or denied
+func (s *SmartContract) WithdrawTransaction(ctx contractapi.TransactionContextInterface, guid string, username string, transactionID string) error {
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return err
+	}
+
+	username = user.Username
+
+	transactions, err := getPendingTransactions(ctx, guid)
+
+	if err != nil {
+		return err
+	}
+
+	index, err := pendingTransactionIndex(transactions, guid, transactionID)
+
+	if err != nil {
+		return err
+	}
+
+	if transactions[index].Suggester != username {
+		return fmt.Errorf("Pending transaction %s for %s was suggested by %s, so %s may not withdraw it", transactionID, guid, transactions[index].Suggester, username)
+	}
+
+	err = resolvePendingTransaction(ctx, guid, transactions, index, pendingStatusWithdrawn, username)
+
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, ChangeEvent{Action: "WithdrawTransaction", ObjectType: pendingObjectType, ID: guid, Actor: username, ChangedFields: []string{"status"}, AffectedIDs: []string{transactionID}})
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// suggestTaxonUpdate suggests through SuggestUpdate that a specimen's taxon be changed
+func suggestTaxonUpdate(h *contractHarness, guid string, updater string, taxon string) {
+	h.t.Helper()
+	args := []string{guid, "", updater, "", "", "", "", taxon}
+	for len(args) < 22 {
+		args = append(args, "")
+	}
+	h.ok("SuggestUpdate", append(args, "misidentified")...)
+}
+
+func pendingTransactions(h *contractHarness, guid string) []PendingTransaction {
+	h.t.Helper()
+	key, _ := h.stub.CreateCompositeKey(pendingObjectType, []string{guid})
+	transactions := []PendingTransaction{}
+
+	if err := json.Unmarshal(h.stub.State[key], &transactions); err != nil {
+		h.t.Fatal(err)
+	}
+
+	return transactions
+}
+
+func TestPendingTransactionsAreResolvedByID(t *testing.T) {
+	h := newContractHarness(t)
+
+	suggestTaxonUpdate(h, "0", "public", "Pomacanthus imperator")
+	suggestTaxonUpdate(h, "0", "public", "Centropyge loricula")
+	transactions := pendingTransactions(h, "0")
+
+	if len(transactions) != 2 || transactions[0].ID == transactions[1].ID || transactions[0].Status != pendingStatusPending {
+		t.Fatalf("Pending transactions are %+v", transactions)
+	}
+
+	//Positions are not ids
+	h.fail("ApproveTransaction", "0", "manager", "0")
+	//Only the suggester may withdraw a suggestion
+	h.fail("WithdrawTransaction", "0", "manager", transactions[0].ID)
+	h.ok("WithdrawTransaction", "0", "public", transactions[0].ID)
+	h.fail("ApproveTransaction", "0", "manager", transactions[0].ID)
+	h.ok("ApproveTransaction", "0", "manager", transactions[1].ID)
+
+	specimen := Specimen{}
+	h.okInto(&specimen, "Query", "0", "manager")
+
+	if specimen.Taxon != "Centropyge loricula" {
+		t.Errorf("Approved suggestion left taxon %s", specimen.Taxon)
+	}
+
+	//Resolved suggestions are kept for audit
+	transactions = pendingTransactions(h, "0")
+
+	if len(transactions) != 2 || transactions[0].Status != pendingStatusWithdrawn || transactions[1].Status != pendingStatusApproved || transactions[1].Resolver != "manager" {
+		t.Errorf("Resolved transactions are %+v", transactions)
+	}
+}
+
+func TestLegacyPendingTransactionsAreIdentifiedByPosition(t *testing.T) {
+	h := newContractHarness(t)
+
+	key, _ := h.stub.CreateCompositeKey(pendingObjectType, []string{"0"})
+	h.putLegacyState(map[string]string{key: `[{"transaction":"Update","arguments":[],"suggester":"public","reason":"typo"}]`})
+
+	h.fail("DenyTransaction", "0", "manager", "legacy-1")
+	h.ok("DenyTransaction", "0", "manager", "legacy-0")
+
+	transactions := pendingTransactions(h, "0")
+
+	if len(transactions) != 1 || transactions[0].Status != pendingStatusDenied {
+		t.Errorf("Denied legacy transactions are %+v", transactions)
+	}
+}
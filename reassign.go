@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+type TaxonReassignment struct {
+	AffectedGuids []string `json:"affectedGuids"`
+	Complete      bool     `json:"complete"`
+}
+
+// reassignTaxon moves up to limit specimens of a collection from one taxon to another, or every such specimen when limit is 0.
+// Reassigned specimens leave the old taxon's index, so calling it again carries on where the last call stopped.
+func reassignTaxon(ctx contractapi.TransactionContextInterface, action string, collection string, username string, oldTaxon string, newTaxon string, limit int) (*TaxonReassignment, error) {
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	username = user.Username
+
+	collect, err := getCollection(ctx, collection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	role := roleIn(user, collection)
+
+	if !strings.Contains(collect.TaxonClass, role) {
+		return nil, fmt.Errorf("%s has role %s but role %s is required to update taxon class", username, role, collect.TaxonClass)
+	}
+
+	if oldTaxon == newTaxon {
+		return nil, fmt.Errorf("The old and new taxon are both %s", oldTaxon)
+	}
+
+	recordIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(collectionTaxonIndex, []string{collection, oldTaxon})
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get record iterator. %s", err.Error())
+	}
+
+	defer recordIterator.Close()
+
+	reassignment := TaxonReassignment{[]string{}, true}
+
+	for recordIterator.HasNext() {
+		if limit > 0 && len(reassignment.AffectedGuids) == limit {
+			reassignment.Complete = false
+			break
+		}
+
+		record, err := recordIterator.Next()
+
+		if err != nil {
+			return nil, fmt.Errorf("Error. %s", err.Error())
+		}
+
+		_, attributes, err := ctx.GetStub().SplitCompositeKey(record.Key)
+
+		if err != nil || len(attributes) != 3 {
+			return nil, fmt.Errorf("Failed to split key %s", record.Key)
+		}
+
+		guid := attributes[2]
+		oldSpecimen, err := getSpecimen(ctx, guid)
+
+		if err != nil {
+			return nil, err
+		}
+
+		remarks := fmt.Sprintf("Reassigned from %s in collection %s", oldTaxon, collection)
+		_, err = recordDetermination(ctx, guid, oldSpecimen, Determination{newTaxon, "", oldSpecimen.Determiner, oldSpecimen.DetermineDate, "taxon reassignment", remarks, "", true, username, "", ""})
+
+		if err != nil {
+			return nil, err
+		}
+
+		specimen := *oldSpecimen
+		specimen.Taxon = newTaxon
+		specimen.Updater = username
+
+		err = validateSpecimen(ctx, guid, collect, oldSpecimen, &specimen, "")
+
+		if err != nil {
+			return nil, err
+		}
+
+		err = indexSpecimen(ctx, guid, oldSpecimen, &specimen)
+
+		if err != nil {
+			return nil, err
+		}
+
+		specimenBytes, _ := json.Marshal(specimen)
+
+		err = putState(ctx, specimenObjectType, guid, specimenBytes)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+		}
+
+		attributionString := fmt.Sprintf("Reassigned specimen with GUID %s from %s to %s", guid, oldTaxon, newTaxon)
+		err = attribute(ctx, username, action, specimenObjectType, guid, guid, attributionString)
+
+		if err != nil {
+			return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+		}
+
+		reassignment.AffectedGuids = append(reassignment.AffectedGuids, guid)
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: action, ObjectType: collectionObjectType, ID: collection, Actor: username, ChangedFields: []string{"taxon", "updater"}, AffectedIDs: reassignment.AffectedGuids})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &reassignment, nil
+}
+
+// ReassignTaxon is UpdateTaxonClass for taxa too large to reassign in one transaction. Submit it again until the result is complete.
+func (s *SmartContract) ReassignTaxon(ctx contractapi.TransactionContextInterface, collection string, username string, oldTaxon string, newTaxon string, pageSize int32) (*TaxonReassignment, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("Page size must be positive")
+	}
+
+	return reassignTaxon(ctx, "ReassignTaxon", collection, username, oldTaxon, newTaxon, int(pageSize))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"testing"
+)
+
+// createSpecimens creates specimens of a taxon in the sample collection
+func createSpecimens(h *contractHarness, taxon string, guids ...string) {
+	h.t.Helper()
+
+	for _, guid := range guids {
+		h.ok("CreateSpecimen", `{"guid":"`+guid+`","updater":"manager","collection":"KU Ornithology","taxon":"`+taxon+`"}`)
+	}
+}
+
+func TestReassignTaxonInPages(t *testing.T) {
+	h := newContractHarness(t)
+	createSpecimens(h, "Pygoplites diacanthus", "a", "b", "c")
+	createSpecimens(h, "Pomacanthus imperator", "d")
+
+	h.fail("ReassignTaxon", "KU Ornithology", "assistant", "Pygoplites diacanthus", "Holacanthus diacanthus", "3")
+	h.fail("ReassignTaxon", "KU Ornithology", "manager", "Pygoplites diacanthus", "Holacanthus diacanthus", "0")
+	h.fail("ReassignTaxon", "KU Ornithology", "manager", "Pygoplites diacanthus", "Pygoplites diacanthus", "3")
+
+	//The sample specimen 0 is of the same taxon, so four specimens are reassigned
+	reassigned := []string{}
+	reassignment := TaxonReassignment{}
+
+	for pages := 0; !reassignment.Complete; pages++ {
+		if pages == 3 {
+			t.Fatalf("Reassignment is not complete after %d pages", pages)
+		}
+
+		h.okInto(&reassignment, "ReassignTaxon", "KU Ornithology", "manager", "Pygoplites diacanthus", "Holacanthus diacanthus", "3")
+		reassigned = append(reassigned, reassignment.AffectedGuids...)
+	}
+
+	if !equalGuids(reassigned, "0", "a", "b", "c") {
+		t.Errorf("Reassigned %v", reassigned)
+	}
+
+	specimen := Specimen{}
+	h.okInto(&specimen, "Query", "d", "manager")
+
+	if specimen.Taxon != "Pomacanthus imperator" {
+		t.Errorf("Specimen of another taxon was reassigned to %s", specimen.Taxon)
+	}
+
+	h.okInto(&specimen, "Query", "a", "manager")
+
+	if specimen.Taxon != "Holacanthus diacanthus" {
+		t.Errorf("Specimen was reassigned to %s", specimen.Taxon)
+	}
+
+	history := DeterminationHistory{}
+	h.okInto(&history, "QueryDeterminations", "a", "public")
+
+	if history.Current == nil || history.Current.Taxon != "Holacanthus diacanthus" || history.Current.Method != "taxon reassignment" {
+		t.Errorf("Reassignment recorded determination %+v", history.Current)
+	}
+}
+
+func TestUpdateTaxonClassReassignsEverySpecimen(t *testing.T) {
+	h := newContractHarness(t)
+	createSpecimens(h, "Pygoplites diacanthus", "a", "b")
+
+	h.fail("UpdateTaxonClass", "KU Ornithology", "assistant", "Pygoplites diacanthus", "Holacanthus diacanthus")
+
+	if reassigned := h.ok("UpdateTaxonClass", "KU Ornithology", "manager", "Pygoplites diacanthus", "Holacanthus diacanthus"); reassigned != "3" {
+		t.Errorf("Reassigned %s specimens", reassigned)
+	}
+
+	event := h.lastEvent()
+
+	if event.Action != "UpdateTaxonClass" || !equalGuids(event.AffectedIDs, "0", "a", "b") {
+		t.Errorf("Reassignment emitted %+v", event)
+	}
+
+	contributors := []Contributor{}
+	h.okInto(&contributors, "QuerySpecimenContributors", "a", "manager")
+
+	for _, contributor := range contributors {
+		if contributor.Username == "manager" && contributor.Actions["UpdateTaxonClass"] != 1 {
+			t.Errorf("Manager is credited with %v", contributor.Actions)
+		}
+	}
+}
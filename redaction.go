@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// RedactionPolicy withholds sensitive specimen data from the lower roles of a collection wherever its specimens are queried
+type RedactionPolicy struct {
+	//Roles the policy applies to, such as "SP". The policy applies to no one when blank.
+	Roles string `json:"roles"`
+	//Decimal places coordinates are rounded to, or -1 to leave them unrounded
+	CoordinatePrecision int  `json:"coordinatePrecision"`
+	OmitCollector       bool `json:"omitCollector"`
+	HideLoans           bool `json:"hideLoans"`
+	HideGrants          bool `json:"hideGrants"`
+}
+
+func (policy *RedactionPolicy) appliesTo(role string) bool {
+	return policy.Roles != "" && strings.Contains(policy.Roles, role)
+}
+
+// redact returns a copy of a specimen without the data the collection's redaction policy withholds from a role, or the specimen itself when nothing is withheld
+func redact(collect *Collection, role string, specimen *Specimen) *Specimen {
+	policy := collect.Redaction
+
+	if !policy.appliesTo(role) {
+		return specimen
+	}
+
+	redacted := *specimen
+
+	if policy.CoordinatePrecision >= 0 {
+		redacted.Latitude = roundCoordinate(specimen.Latitude, policy.CoordinatePrecision)
+		redacted.Longitude = roundCoordinate(specimen.Longitude, policy.CoordinatePrecision)
+	}
+	if policy.OmitCollector {
+		redacted.Collector = ""
+	}
+	if policy.HideLoans {
+		redacted.Loans = ""
+	}
+	if policy.HideGrants {
+		redacted.Grants = ""
+	}
+
+	return &redacted
+}
+
+// redact returns the specimen as the user may see it under its collection's redaction policy
+func (p *queryPermissions) redact(ctx contractapi.TransactionContextInterface, specimen *Specimen) (*Specimen, error) {
+	collect, err := p.collection(ctx, specimen.Collection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return redact(collect, roleIn(p.user, specimen.Collection), specimen), nil
+}
+
+// withholds reports whether the collection's redaction policy hides its loans or grants, chosen by objectType, from the user
+func (p *queryPermissions) withholds(ctx contractapi.TransactionContextInterface, collection string, objectType string) (bool, error) {
+	collect, err := p.collection(ctx, collection)
+
+	if err != nil {
+		return false, err
+	}
+
+	if !collect.Redaction.appliesTo(roleIn(p.user, collection)) {
+		return false, nil
+	}
+
+	switch objectType {
+	case loanObjectType:
+		return collect.Redaction.HideLoans, nil
+	case grantObjectType:
+		return collect.Redaction.HideGrants, nil
+	}
+
+	return false, nil
+}
+
+// unwithheldQueryPermissions checks that the user may query a collection and that its redaction policy does not withhold its loans or grants, chosen by objectType, from the user
+func unwithheldQueryPermissions(ctx contractapi.TransactionContextInterface, collection string, username string, objectType string) (*queryPermissions, error) {
+	permissions, err := collectionQueryPermissions(ctx, collection, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	withheld, err := permissions.withholds(ctx, collection, objectType)
+
+	if err != nil {
+		return nil, err
+	}
+	if withheld {
+		return nil, fmt.Errorf("%s%ss of collection %s are withheld from role %s", strings.ToUpper(objectType[:1]), objectType[1:], collection, roleIn(permissions.user, collection))
+	}
+
+	return permissions, nil
+}
+
+// callerPermissions returns the permissions of the user linked to the submitting identity, for the queries which take no username.
+// An identity linked to no user is public in every collection in compatible mode, and rejected as in getUser otherwise.
+func callerPermissions(ctx contractapi.TransactionContextInterface) (*queryPermissions, error) {
+	callerName, err := resolveCaller(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if callerName == "" {
+		config, err := getConfig(ctx)
+
+		if err != nil {
+			return nil, err
+		}
+		if config.IdentityMode != identityModeCompatible {
+			return nil, fmt.Errorf("Submitting identity is not linked to a registered user")
+		}
+
+		return newQueryPermissions(&User{Membership: make(map[string]string)}), nil
+	}
+
+	user, err := getUser(ctx, callerName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newQueryPermissions(user), nil
+}
+
+// SetRedactionPolicy sets the data withheld from the roles of a collection when they query its specimens.
+// Coordinates are rounded to coordinatePrecision decimal places, or left unrounded when it is -1.
+func (s *SmartContract) SetRedactionPolicy(ctx contractapi.TransactionContextInterface, name string, username string, roles string, coordinatePrecision int, omitCollector bool, hideLoans bool, hideGrants bool) (*Collection, error) {
+	collect, err := getCollection(ctx, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	username = user.Username
+
+	if role, ok := user.Membership[name]; ok {
+		if role != "M" {
+			return nil, fmt.Errorf("%s is not the Manager for collection %s", username, name)
+		}
+	} else {
+		return nil, fmt.Errorf("%s is not registered with collection %s", username, name)
+	}
+
+	//Managers always see their collection's specimens in full
+	for _, role := range roles {
+		if !strings.ContainsRune("CASP", role) {
+			return nil, fmt.Errorf("%c is not a role a redaction policy may apply to. Valid roles are C, A, S, and P", role)
+		}
+	}
+
+	if coordinatePrecision < -1 || coordinatePrecision > maxCoordinatePrecision {
+		return nil, fmt.Errorf("Coordinate precision must be -1 or between 0 and %d decimal places", maxCoordinatePrecision)
+	}
+
+	oldCollection := *collect
+	collect.Redaction = RedactionPolicy{roles, coordinatePrecision, omitCollector, hideLoans, hideGrants}
+
+	attributionString := fmt.Sprintf("Updated Collection %s redaction policy", name)
+	err = attribute(ctx, username, "SetRedactionPolicy", collectionObjectType, name, "", attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	collectionBytes, _ := json.Marshal(collect)
+	err = putState(ctx, collectionObjectType, name, collectionBytes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "SetRedactionPolicy", ObjectType: collectionObjectType, ID: name, Actor: username, ChangedFields: changedFields(&oldCollection, collect)})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return collect, nil
+}
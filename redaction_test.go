@@ -0,0 +1,102 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSetRedactionPolicy(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.fail("SetRedactionPolicy", "KU Ornithology", "curator", "SP", "1", "true", "true", "false")
+	h.fail("SetRedactionPolicy", "KU Ornithology", "manager", "MSP", "1", "true", "true", "false")
+	h.fail("SetRedactionPolicy", "KU Ornithology", "manager", "SP", "7", "true", "true", "false")
+	h.fail("SetRedactionPolicy", "KU Ornithology", "manager", "SP", "-2", "true", "true", "false")
+
+	collect := Collection{}
+	h.okInto(&collect, "SetRedactionPolicy", "KU Ornithology", "manager", "SP", "1", "true", "true", "false")
+
+	if collect.Redaction != (RedactionPolicy{"SP", 1, true, true, false}) {
+		t.Errorf("Redaction policy is %+v", collect.Redaction)
+	}
+}
+
+func TestRedactionAppliesToPolicyRoles(t *testing.T) {
+	h := newContractHarness(t)
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"manager","collector":"Smith, J"}`)
+	h.ok("SetRedactionPolicy", "KU Ornithology", "manager", "SP", "1", "true", "true", "false")
+
+	specimen := Specimen{}
+
+	for _, username := range []string{"student", "public"} {
+		h.okInto(&specimen, "Query", "0", username)
+
+		if specimen.Latitude != "18.1" || specimen.Longitude != "-178.4" || specimen.Collector != "" {
+			t.Errorf("%s sees %s, %s collected by %q", username, specimen.Latitude, specimen.Longitude, specimen.Collector)
+		}
+	}
+
+	for _, username := range []string{"manager", "assistant"} {
+		h.okInto(&specimen, "Query", "0", username)
+
+		if specimen.Latitude != "18.1483325958" || specimen.Collector != "Smith, J" {
+			t.Errorf("%s sees %s collected by %q", username, specimen.Latitude, specimen.Collector)
+		}
+	}
+
+	//An identity linked to no user is public in every collection
+	results := []QueryResult{}
+	h.okInto(&results, "QueryAllSpecimens")
+
+	if len(results) != 1 || results[0].Record.Latitude != "18.1" || results[0].Record.Collector != "" {
+		t.Errorf("Unlinked identity sees %+v", results)
+	}
+
+	page := OccurrencePage{}
+	h.okInto(&page, "ExportDarwinCore", "KU Ornithology", "public", "10", "")
+
+	if len(page.Records) != 1 || page.Records[0].DecimalLatitude != "18.1" || page.Records[0].RecordedBy != "" {
+		t.Errorf("Public export is %+v", page.Records)
+	}
+
+	//Coordinates are left unrounded at a precision of -1
+	h.ok("SetRedactionPolicy", "KU Ornithology", "manager", "SP", "-1", "false", "false", "false")
+	h.okInto(&specimen, "Query", "0", "public")
+
+	if specimen.Latitude != "18.1483325958" || specimen.Collector != "Smith, J" {
+		t.Errorf("Public sees %s collected by %q", specimen.Latitude, specimen.Collector)
+	}
+}
+
+func TestWritesReturnSpecimensRedacted(t *testing.T) {
+	h := newContractHarness(t)
+	h.ok("PatchSpecimen", `{"guid":"0","updater":"manager","collector":"Smith, J"}`)
+	h.ok("SetRedactionPolicy", "KU Ornithology", "manager", "SP", "1", "true", "false", "false")
+	h.ok("UpdateCollection", "KU Ornithology", "manager", "MS", "MC", "MCA", "MCA", "MCAS", "MCA", "MC", "MC", "MCA", "MCAS", "MCAS", "MCASP", "MCASP")
+
+	specimen := Specimen{}
+	h.okInto(&specimen, "PatchSpecimen", `{"guid":"0","updater":"student","image":"https://example.org/0.jpg"}`)
+
+	if specimen.Latitude != "18.1" || specimen.Collector != "" || specimen.Image != "https://example.org/0.jpg" {
+		t.Errorf("Student's patch returned %s collected by %q", specimen.Latitude, specimen.Collector)
+	}
+
+	h.okInto(&specimen, "CreateSpecimen", `{"guid":"s1","updater":"student","collection":"KU Ornithology","latitude":"38.9543","longitude":"-95.2558","collector":"Jones, K"}`)
+
+	if specimen.Latitude != "39.0" || specimen.Collector != "" {
+		t.Errorf("Student's new specimen was returned as %s collected by %q", specimen.Latitude, specimen.Collector)
+	}
+
+	minted := QueryResult{}
+	h.okInto(&minted, "MintSpecimen", `{"updater":"student","collection":"KU Ornithology","latitude":"38.9543","longitude":"-95.2558","collector":"Jones, K"}`)
+
+	if minted.Record.Longitude != "-95.3" || minted.Record.Collector != "" {
+		t.Errorf("Student's minted specimen was returned as %+v", minted.Record)
+	}
+
+	//The stored specimen is not redacted
+	h.okInto(&specimen, "Query", "0", "manager")
+
+	if specimen.Latitude != "18.1483325958" || specimen.Collector != "Smith, J" {
+		t.Errorf("Manager sees %s collected by %q", specimen.Latitude, specimen.Collector)
+	}
+}
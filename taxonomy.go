@@ -0,0 +1,499 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+const (
+	taxonStatusAccepted = "accepted"
+	taxonStatusSynonym  = "synonym"
+)
+
+var taxonRanks = []string{"kingdom", "phylum", "class", "order", "family", "subfamily", "tribe", "genus", "subgenus", "species", "subspecies", "variety", "form"}
+
+// Taxon is a name in the taxonomy registry. Specimens refer to taxa by name, so a specimen of a synonym is also a specimen of its accepted taxon.
+type Taxon struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Rank       string `json:"rank"`
+	Parent     string `json:"parent"`
+	Author     string `json:"author"`
+	Year       string `json:"year"`
+	Status     string `json:"status"`
+	AcceptedID string `json:"acceptedId"`
+	Registrar  string `json:"registrar"`
+}
+
+// rankLevel returns the position of a rank in taxonRanks, from kingdom down, or -1 for a rank which is not valid
+func rankLevel(rank string) int {
+	for level, taxonRank := range taxonRanks {
+		if rank == taxonRank {
+			return level
+		}
+	}
+
+	return -1
+}
+
+func getTaxon(ctx contractapi.TransactionContextInterface, taxonID string) (*Taxon, error) {
+	taxonBytes, err := getState(ctx, taxonObjectType, taxonID)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if taxonBytes == nil {
+		return nil, fmt.Errorf("Taxon %s does not exist", taxonID)
+	}
+
+	taxon := new(Taxon)
+	_ = json.Unmarshal(taxonBytes, taxon)
+
+	return taxon, nil
+}
+
+// putTaxon stores a taxon and moves its index keys from where oldTaxon had them. oldTaxon is nil for a new taxon.
+func putTaxon(ctx contractapi.TransactionContextInterface, oldTaxon *Taxon, taxon *Taxon) error {
+	if oldTaxon != nil {
+		err := putIndex(ctx, false, taxonNameIndex, oldTaxon.Name, oldTaxon.ID)
+
+		if err != nil {
+			return err
+		}
+
+		err = putIndex(ctx, false, taxonChildIndex, oldTaxon.Parent, oldTaxon.ID)
+
+		if err != nil {
+			return err
+		}
+
+		err = putIndex(ctx, false, taxonSynonymIndex, oldTaxon.AcceptedID, oldTaxon.ID)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	err := putIndex(ctx, true, taxonNameIndex, taxon.Name, taxon.ID)
+
+	if err != nil {
+		return err
+	}
+
+	err = putIndex(ctx, taxon.Parent != "", taxonChildIndex, taxon.Parent, taxon.ID)
+
+	if err != nil {
+		return err
+	}
+
+	err = putIndex(ctx, taxon.Status == taxonStatusSynonym, taxonSynonymIndex, taxon.AcceptedID, taxon.ID)
+
+	if err != nil {
+		return err
+	}
+
+	taxonBytes, _ := json.Marshal(taxon)
+
+	err = putState(ctx, taxonObjectType, taxon.ID, taxonBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// taxonomyAccess checks that the user may edit the taxonomy registry, which is shared by every collection.
+// A collection role is not enough, since anyone may register a collection and manage it, so the submitting identity must carry the admin or taxonomist attribute.
+func taxonomyAccess(ctx contractapi.TransactionContextInterface, username string) (*User, error) {
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !isAdmin(ctx) && ctx.GetClientIdentity().AssertAttributeValue(taxonomistAttribute, "true") != nil {
+		return nil, fmt.Errorf("Only an identity with the %s or %s attribute may edit the taxonomy", adminAttribute, taxonomistAttribute)
+	}
+
+	return user, nil
+}
+
+// validateTaxon checks that a specimen taxon is a name in the taxonomy registry when taxa are validated
+func validateTaxon(ctx contractapi.TransactionContextInterface, taxon string) error {
+	if taxon == "" {
+		return nil
+	}
+
+	config, err := getConfig(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if !config.ValidateTaxa {
+		return nil
+	}
+
+	taxonIDs, err := indexedIDs(ctx, taxonNameIndex, []string{taxon})
+
+	if err != nil {
+		return err
+	}
+
+	if len(taxonIDs) == 0 {
+		return fmt.Errorf("Taxon %s is not in the taxonomy registry", taxon)
+	}
+
+	return nil
+}
+
+func (s *SmartContract) AddTaxon(ctx contractapi.TransactionContextInterface, taxonID string, username string, name string, rank string, parentID string, author string, year string) (*Taxon, error) {
+	user, err := taxonomyAccess(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	username = user.Username
+
+	if taxonID == "" || name == "" {
+		return nil, fmt.Errorf("A taxon requires an id and a name")
+	}
+
+	checkExistence, err := getState(ctx, taxonObjectType, taxonID)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if checkExistence != nil {
+		return nil, fmt.Errorf("Taxon %s already exists", taxonID)
+	}
+
+	if rankLevel(rank) == -1 {
+		return nil, fmt.Errorf("%s is not a valid rank. Valid ranks are %s", rank, strings.Join(taxonRanks, ", "))
+	}
+
+	if parentID != "" {
+		parent, err := getTaxon(ctx, parentID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if rankLevel(parent.Rank) >= rankLevel(rank) {
+			return nil, fmt.Errorf("Parent taxon %s is a %s, which does not rank above %s", parentID, parent.Rank, rank)
+		}
+	}
+
+	taxon := Taxon{taxonID, name, rank, parentID, author, year, taxonStatusAccepted, "", username}
+
+	err = putTaxon(ctx, nil, &taxon)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attributionString := fmt.Sprintf("Added %s %s to the taxonomy", rank, name)
+	err = attribute(ctx, username, "AddTaxon", taxonObjectType, taxonID, "", attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "AddTaxon", ObjectType: taxonObjectType, ID: taxonID, Actor: username, ChangedFields: changedFields(Taxon{}, taxon)})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &taxon, nil
+}
+
+// synonymize makes a taxon a synonym of an accepted taxon. Synonyms of the taxon become synonyms of the accepted taxon, and so do its children when they are moved.
+func synonymize(ctx contractapi.TransactionContextInterface, action string, taxonID string, username string, acceptedID string, moveChildren bool) (*Taxon, error) {
+	user, err := taxonomyAccess(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	username = user.Username
+
+	if taxonID == acceptedID {
+		return nil, fmt.Errorf("Taxon %s cannot be a synonym of itself", taxonID)
+	}
+
+	taxon, err := getTaxon(ctx, taxonID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	accepted, err := getTaxon(ctx, acceptedID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if accepted.Status != taxonStatusAccepted {
+		return nil, fmt.Errorf("Taxon %s is itself a synonym of %s", acceptedID, accepted.AcceptedID)
+	}
+
+	//Moving a taxon's children under one of its descendants would make that descendant its own ancestor
+	if moveChildren {
+		visited := map[string]bool{}
+
+		for ancestor := accepted; ancestor.Parent != "" && !visited[ancestor.Parent]; {
+			if ancestor.Parent == taxonID {
+				return nil, fmt.Errorf("Taxon %s cannot be merged into %s, which is below it", taxonID, acceptedID)
+			}
+
+			visited[ancestor.Parent] = true
+			ancestor, err = getTaxon(ctx, ancestor.Parent)
+
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	affectedIDs := []string{acceptedID}
+
+	synonymIDs, err := indexedIDs(ctx, taxonSynonymIndex, []string{taxonID})
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, synonymID := range synonymIDs {
+		synonym, err := getTaxon(ctx, synonymID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		oldSynonym := *synonym
+		synonym.AcceptedID = acceptedID
+
+		err = putTaxon(ctx, &oldSynonym, synonym)
+
+		if err != nil {
+			return nil, err
+		}
+
+		affectedIDs = append(affectedIDs, synonymID)
+	}
+
+	if moveChildren {
+		childIDs, err := indexedIDs(ctx, taxonChildIndex, []string{taxonID})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, childID := range childIDs {
+			child, err := getTaxon(ctx, childID)
+
+			if err != nil {
+				return nil, err
+			}
+
+			oldChild := *child
+			child.Parent = acceptedID
+
+			err = putTaxon(ctx, &oldChild, child)
+
+			if err != nil {
+				return nil, err
+			}
+
+			affectedIDs = append(affectedIDs, childID)
+		}
+	}
+
+	oldTaxon := *taxon
+	taxon.Status = taxonStatusSynonym
+	taxon.AcceptedID = acceptedID
+
+	err = putTaxon(ctx, &oldTaxon, taxon)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attributionString := fmt.Sprintf("Made %s a synonym of %s", taxon.Name, accepted.Name)
+	err = attribute(ctx, username, action, taxonObjectType, taxonID, "", attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: action, ObjectType: taxonObjectType, ID: taxonID, Actor: username, ChangedFields: changedFields(&oldTaxon, taxon), AffectedIDs: affectedIDs})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return taxon, nil
+}
+
+func (s *SmartContract) MarkSynonym(ctx contractapi.TransactionContextInterface, taxonID string, username string, acceptedID string) (*Taxon, error) {
+	return synonymize(ctx, "MarkSynonym", taxonID, username, acceptedID, false)
+}
+
+// MergeTaxa sinks one taxon into another, making it a synonym and moving its children under the taxon it was merged into
+func (s *SmartContract) MergeTaxa(ctx contractapi.TransactionContextInterface, taxonID string, username string, intoID string) (*Taxon, error) {
+	return synonymize(ctx, "MergeTaxa", taxonID, username, intoID, true)
+}
+
+// SetTaxonValidation turns checking specimen taxa against the taxonomy registry on or off
+func (s *SmartContract) SetTaxonValidation(ctx contractapi.TransactionContextInterface, enabled bool) error {
+	if !isAdmin(ctx) {
+		return fmt.Errorf("Only an identity with the %s attribute may change taxon validation", adminAttribute)
+	}
+
+	config, err := getConfig(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	oldConfig := *config
+	config.ValidateTaxa = enabled
+	configBytes, _ := json.Marshal(config)
+	err = putState(ctx, configObjectType, "", configBytes)
+
+	if err != nil {
+		return err
+	}
+
+	actor, err := clientIdentityID(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	return emitEvent(ctx, ChangeEvent{Action: "SetTaxonValidation", ObjectType: configObjectType, Actor: actor, ChangedFields: changedFields(&oldConfig, config)})
+}
+
+func (s *SmartContract) QueryTaxon(ctx contractapi.TransactionContextInterface, taxonID string) (*Taxon, error) {
+	return getTaxon(ctx, taxonID)
+}
+
+// QueryTaxaByName returns every taxon with a name, since homonyms in different groups share names
+func (s *SmartContract) QueryTaxaByName(ctx contractapi.TransactionContextInterface, name string) ([]Taxon, error) {
+	taxonIDs, err := indexedIDs(ctx, taxonNameIndex, []string{name})
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := []Taxon{}
+
+	for _, taxonID := range taxonIDs {
+		taxon, err := getTaxon(ctx, taxonID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, *taxon)
+	}
+
+	return results, nil
+}
+
+// taxonNames returns the names of a taxon, every taxon below it and all of their synonyms
+func taxonNames(ctx contractapi.TransactionContextInterface, taxonID string) ([]string, error) {
+	visited := map[string]bool{}
+	seen := map[string]bool{}
+	names := []string{}
+	queue := []string{taxonID}
+
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+
+		taxon, err := getTaxon(ctx, id)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !seen[taxon.Name] {
+			seen[taxon.Name] = true
+			names = append(names, taxon.Name)
+		}
+
+		childIDs, err := indexedIDs(ctx, taxonChildIndex, []string{id})
+
+		if err != nil {
+			return nil, err
+		}
+
+		synonymIDs, err := indexedIDs(ctx, taxonSynonymIndex, []string{id})
+
+		if err != nil {
+			return nil, err
+		}
+
+		queue = append(queue, childIDs...)
+		queue = append(queue, synonymIDs...)
+	}
+
+	return names, nil
+}
+
+// QueryTaxonSpecimens returns the specimens of a collection which belong to a taxon, to any taxon below it (e.g. every species of a genus), or to a synonym of any of them
+func (s *SmartContract) QueryTaxonSpecimens(ctx contractapi.TransactionContextInterface, taxonID string, collection string, username string) ([]QueryResult, error) {
+	permissions, err := collectionQueryPermissions(ctx, collection, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := taxonNames(ctx, taxonID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := []QueryResult{}
+
+	for _, name := range names {
+		guids, err := indexedIDs(ctx, collectionTaxonIndex, []string{collection, name})
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, guid := range guids {
+			specimen, err := getSpecimen(ctx, guid)
+
+			if err != nil {
+				return nil, err
+			}
+
+			specimen, err = permissions.redact(ctx, specimen)
+
+			if err != nil {
+				return nil, err
+			}
+
+			results = append(results, QueryResult{guid, specimen})
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"testing"
+)
+
+// addAngelfishTaxa registers a family with two genera and a species in each
+// asTaxonomist submits the following transactions with an identity which may edit the taxonomy
+func asTaxonomist(h *contractHarness) {
+	h.as("taxonomist", map[string]string{taxonomistAttribute: "true"})
+}
+
+// addAngelfishTaxa adds taxa as a taxonomist, leaving the taxonomist identity to submit the following transactions
+func addAngelfishTaxa(h *contractHarness) {
+	h.t.Helper()
+	asTaxonomist(h)
+	h.ok("AddTaxon", "f1", "manager", "Pomacanthidae", "family", "", "Jordan & Evermann", "1898")
+	h.ok("AddTaxon", "g1", "manager", "Pygoplites", "genus", "f1", "Fraser-Brunner", "1933")
+	h.ok("AddTaxon", "g2", "manager", "Holacanthus", "genus", "f1", "", "")
+	h.ok("AddTaxon", "s1", "manager", "Pygoplites diacanthus", "species", "g1", "Boddaert", "1772")
+	h.ok("AddTaxon", "s2", "manager", "Holacanthus diacanthus", "species", "g2", "", "")
+}
+
+func TestAddTaxon(t *testing.T) {
+	h := newContractHarness(t)
+	addAngelfishTaxa(h)
+
+	h.fail("AddTaxon", "x", "nobody", "Centropyge", "genus", "f1", "", "")
+	h.fail("AddTaxon", "x", "manager", "Centropyge", "clade", "f1", "", "")
+	h.fail("AddTaxon", "x", "manager", "Centropyge", "genus", "missing", "", "")
+	h.fail("AddTaxon", "f1", "manager", "Centropyge", "genus", "f1", "", "")
+
+	//A parent must rank above its child
+	h.fail("AddTaxon", "x", "manager", "Centropyge", "genus", "g1", "", "")
+	h.fail("AddTaxon", "x", "manager", "Centropyge", "order", "f1", "", "")
+
+	taxon := Taxon{}
+	h.okInto(&taxon, "AddTaxon", "x", "manager", "Centropyge", "genus", "f1", "Kaup", "1860")
+
+	if taxon.Parent != "f1" || taxon.Status != taxonStatusAccepted || taxon.Registrar != "manager" {
+		t.Errorf("Added taxon %+v", taxon)
+	}
+
+	taxa := []Taxon{}
+	h.okInto(&taxa, "QueryTaxaByName", "Centropyge")
+
+	if len(taxa) != 1 || taxa[0].ID != "x" {
+		t.Errorf("Taxa named Centropyge are %+v", taxa)
+	}
+}
+
+func TestCollectionRolesDoNotGrantTaxonomyAccess(t *testing.T) {
+	h := newContractHarness(t)
+
+	//Managing a collection, even one registered just for this, does not allow editing the shared registry
+	h.fail("AddTaxon", "f1", "manager", "Pomacanthidae", "family", "", "", "")
+	h.ok("RegisterUser", "mallory")
+	h.ok("RegisterCollection", "Mallory's Collection", "mallory", "M", "M", "M", "M", "M", "M", "M", "M", "M", "M", "M", "M", "M")
+	h.fail("AddTaxon", "f1", "mallory", "Pomacanthidae", "family", "", "", "")
+
+	h.asAdmin()
+	h.ok("AddTaxon", "f1", "manager", "Pomacanthidae", "family", "", "", "")
+
+	asTaxonomist(h)
+	h.ok("AddTaxon", "g1", "mallory", "Pygoplites", "genus", "f1", "", "")
+
+	h.as("app", map[string]string{taxonomistAttribute: "false"})
+	h.fail("MergeTaxa", "g1", "manager", "f1")
+}
+
+func TestMarkSynonym(t *testing.T) {
+	h := newContractHarness(t)
+	addAngelfishTaxa(h)
+
+	h.fail("MarkSynonym", "s2", "manager", "s2")
+	h.ok("MarkSynonym", "s2", "manager", "s1")
+	h.fail("MarkSynonym", "s1", "manager", "s2")
+
+	createSpecimens(h, "Holacanthus diacanthus", "synonym")
+
+	results := []QueryResult{}
+	h.okInto(&results, "QueryTaxonSpecimens", "g1", "KU Ornithology", "manager")
+
+	if guids := resultGuids(results); !equalGuids(guids, "0", "synonym") {
+		t.Errorf("Specimens of Pygoplites are %v", guids)
+	}
+}
+
+func TestMergeTaxa(t *testing.T) {
+	h := newContractHarness(t)
+	addAngelfishTaxa(h)
+
+	//Merging a taxon into its own descendants would make a cycle
+	h.fail("MergeTaxa", "f1", "manager", "g1")
+	h.fail("MergeTaxa", "f1", "manager", "s1")
+	h.fail("MergeTaxa", "g1", "manager", "s1")
+
+	merged := Taxon{}
+	h.okInto(&merged, "MergeTaxa", "g2", "manager", "g1")
+
+	if merged.Status != taxonStatusSynonym || merged.AcceptedID != "g1" {
+		t.Errorf("Merged taxon is %+v", merged)
+	}
+
+	moved := Taxon{}
+	h.okInto(&moved, "QueryTaxon", "s2")
+
+	if moved.Parent != "g1" {
+		t.Errorf("Child of merged taxon is under %s", moved.Parent)
+	}
+}
+
+func TestTaxonValidation(t *testing.T) {
+	h := newContractHarness(t)
+	addAngelfishTaxa(h)
+
+	h.fail("SetTaxonValidation", "true")
+
+	h.asAdmin()
+	h.ok("SetTaxonValidation", "true")
+
+	h.as("app", nil)
+	h.fail("CreateSpecimen", `{"guid":"a","updater":"manager","collection":"KU Ornithology","taxon":"Centropyge loricula"}`)
+	createSpecimens(h, "Pygoplites", "a")
+	h.fail("ReassignTaxon", "KU Ornithology", "manager", "Pygoplites", "Centropyge", "1")
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// TaxonSuggestion is a proposed determination, queued apart from the PendingTransactions of SuggestUpdate so that taxonomists can work through them on their own
+type TaxonSuggestion struct {
+	ID              string `json:"id"`
+	Guid            string `json:"guid"`
+	Collection      string `json:"collection"`
+	Taxon           string `json:"taxon"`
+	Determiner      string `json:"determiner"`
+	DetermineDate   string `json:"determineDate"`
+	Confidence      string `json:"confidence"`
+	Rationale       string `json:"rationale"`
+	Suggester       string `json:"suggester"`
+	Created         string `json:"created"`
+	Status          string `json:"status"`
+	Resolver        string `json:"resolver"`
+	Resolved        string `json:"resolved"`
+	ResolutionNotes string `json:"resolutionNotes"`
+}
+
+func getTaxonSuggestion(ctx contractapi.TransactionContextInterface, suggestionID string) (*TaxonSuggestion, error) {
+	suggestionBytes, err := getState(ctx, taxonSuggestionObjectType, suggestionID)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	if suggestionBytes == nil {
+		return nil, fmt.Errorf("Taxon suggestion %s does not exist", suggestionID)
+	}
+
+	suggestion := new(TaxonSuggestion)
+	_ = json.Unmarshal(suggestionBytes, suggestion)
+
+	return suggestion, nil
+}
+
+func putTaxonSuggestion(ctx contractapi.TransactionContextInterface, suggestion *TaxonSuggestion) error {
+	err := putIndex(ctx, suggestion.Status == pendingStatusPending, pendingTaxonSuggestionIndex, suggestion.Collection, suggestion.ID)
+
+	if err != nil {
+		return err
+	}
+
+	err = putIndex(ctx, true, specimenTaxonSuggestionIndex, suggestion.Guid, suggestion.ID)
+
+	if err != nil {
+		return err
+	}
+
+	suggestionBytes, _ := json.Marshal(suggestion)
+
+	err = putState(ctx, taxonSuggestionObjectType, suggestion.ID, suggestionBytes)
+
+	if err != nil {
+		return fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	return nil
+}
+
+// taxonSuggestionAccess loads a pending taxon suggestion and checks that the user holds the collection's taxonName role
+func taxonSuggestionAccess(ctx contractapi.TransactionContextInterface, suggestionID string, username string) (*TaxonSuggestion, *User, error) {
+	suggestion, err := getTaxonSuggestion(ctx, suggestionID)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if suggestion.Status != pendingStatusPending {
+		return nil, nil, fmt.Errorf("Taxon suggestion %s is already %s", suggestionID, suggestion.Status)
+	}
+
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	collect, err := getCollection(ctx, suggestion.Collection)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	role := roleIn(user, suggestion.Collection)
+
+	if !strings.Contains(collect.TaxonName, role) {
+		return nil, nil, fmt.Errorf("%s has role %s but role %s is required to update taxon name", user.Username, role, collect.TaxonName)
+	}
+
+	return suggestion, user, nil
+}
+
+// resolveTaxonSuggestion records how a taxon suggestion was resolved and takes it off its collection's queue
+func resolveTaxonSuggestion(ctx contractapi.TransactionContextInterface, suggestion *TaxonSuggestion, status string, username string, notes string) error {
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	suggestion.Status = status
+	suggestion.Resolver = username
+	suggestion.Resolved = now.Format(time.RFC3339)
+	suggestion.ResolutionNotes = notes
+
+	return putTaxonSuggestion(ctx, suggestion)
+}
+
+func (s *SmartContract) SuggestTaxon(ctx contractapi.TransactionContextInterface, guid string, username string, taxon string, determiner string, determineDate string, confidence string, rationale string) (*TaxonSuggestion, error) {
+	specimen, err := getSpecimen(ctx, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	username = user.Username
+
+	collect, err := getCollection(ctx, specimen.Collection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	role := roleIn(user, specimen.Collection)
+
+	if !strings.Contains(collect.SuggestTaxon, role) {
+		return nil, fmt.Errorf("%s has role %s but role %s is required to suggest taxon names", username, role, collect.SuggestTaxon)
+	}
+
+	if taxon == "" {
+		return nil, fmt.Errorf("A taxon suggestion requires a taxon")
+	}
+
+	now, err := txTime(ctx)
+
+	if err != nil {
+		return nil, err
+	}
+
+	suggestion := TaxonSuggestion{ctx.GetStub().GetTxID(), guid, specimen.Collection, taxon, determiner, determineDate, confidence, rationale, username, now.Format(time.RFC3339), pendingStatusPending, "", "", ""}
+
+	err = putTaxonSuggestion(ctx, &suggestion)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attributionString := fmt.Sprintf("Suggested taxon %s for specimen with GUID %s", taxon, guid)
+	err = attribute(ctx, username, "SuggestTaxon", taxonSuggestionObjectType, suggestion.ID, guid, attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "SuggestTaxon", ObjectType: taxonSuggestionObjectType, ID: suggestion.ID, Actor: username, ChangedFields: changedFields(TaxonSuggestion{}, suggestion), AffectedIDs: []string{guid}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &suggestion, nil
+}
+
+// ApproveTaxonSuggestion makes the suggested determination the specimen's current determination, recording the suggester's confidence and rationale with it
+func (s *SmartContract) ApproveTaxonSuggestion(ctx contractapi.TransactionContextInterface, suggestionID string, username string, notes string) (*Specimen, error) {
+	suggestion, user, err := taxonSuggestionAccess(ctx, suggestionID, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	username = user.Username
+
+	err = resolveTaxonSuggestion(ctx, suggestion, pendingStatusApproved, username, notes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attributionString := fmt.Sprintf("Approved taxon suggestion %s for specimen with GUID %s", suggestionID, suggestion.Guid)
+	err = attribute(ctx, username, "ApproveTaxonSuggestion", taxonSuggestionObjectType, suggestionID, suggestion.Guid, attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	patch := SpecimenPatch{Guid: suggestion.Guid, Updater: username, Taxon: &suggestion.Taxon, DeterminationRemarks: suggestion.Rationale, DeterminationConfidence: suggestion.Confidence}
+
+	//A suggestion without a determiner or date leaves the specimen's own in place rather than clearing them
+	if suggestion.Determiner != "" {
+		patch.Determiner = &suggestion.Determiner
+	}
+	if suggestion.DetermineDate != "" {
+		patch.DetermineDate = &suggestion.DetermineDate
+	}
+
+	patched, _, err := s.patchSpecimen(ctx, "ApproveTaxonSuggestion", &patch)
+
+	return patched, err
+}
+
+func (s *SmartContract) DenyTaxonSuggestion(ctx contractapi.TransactionContextInterface, suggestionID string, username string, reason string) (*TaxonSuggestion, error) {
+	suggestion, user, err := taxonSuggestionAccess(ctx, suggestionID, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	username = user.Username
+	oldSuggestion := *suggestion
+
+	err = resolveTaxonSuggestion(ctx, suggestion, pendingStatusDenied, username, reason)
+
+	if err != nil {
+		return nil, err
+	}
+
+	attributionString := fmt.Sprintf("Denied taxon suggestion %s for specimen with GUID %s", suggestionID, suggestion.Guid)
+	err = attribute(ctx, username, "DenyTaxonSuggestion", taxonSuggestionObjectType, suggestionID, suggestion.Guid, attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "DenyTaxonSuggestion", ObjectType: taxonSuggestionObjectType, ID: suggestionID, Actor: username, ChangedFields: changedFields(&oldSuggestion, suggestion), AffectedIDs: []string{suggestion.Guid}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return suggestion, nil
+}
+
+func (s *SmartContract) WithdrawTaxonSuggestion(ctx contractapi.TransactionContextInterface, suggestionID string, username string) (*TaxonSuggestion, error) {
+	suggestion, err := getTaxonSuggestion(ctx, suggestionID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	username = user.Username
+
+	if suggestion.Status != pendingStatusPending {
+		return nil, fmt.Errorf("Taxon suggestion %s is already %s", suggestionID, suggestion.Status)
+	}
+	if suggestion.Suggester != username {
+		return nil, fmt.Errorf("Taxon suggestion %s was suggested by %s, so %s may not withdraw it", suggestionID, suggestion.Suggester, username)
+	}
+
+	oldSuggestion := *suggestion
+
+	err = resolveTaxonSuggestion(ctx, suggestion, pendingStatusWithdrawn, username, "")
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "WithdrawTaxonSuggestion", ObjectType: taxonSuggestionObjectType, ID: suggestionID, Actor: username, ChangedFields: changedFields(&oldSuggestion, suggestion), AffectedIDs: []string{suggestion.Guid}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return suggestion, nil
+}
+
+func taxonSuggestionsByIndex(ctx contractapi.TransactionContextInterface, index string, attributes []string) ([]TaxonSuggestion, error) {
+	suggestionIDs, err := indexedIDs(ctx, index, attributes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	results := []TaxonSuggestion{}
+
+	for _, suggestionID := range suggestionIDs {
+		suggestion, err := getTaxonSuggestion(ctx, suggestionID)
+
+		if err != nil {
+			return nil, err
+		}
+
+		results = append(results, *suggestion)
+	}
+
+	return results, nil
+}
+
+// QueryTaxonSuggestions returns every taxon suggestion ever made for a specimen, including resolved ones
+func (s *SmartContract) QueryTaxonSuggestions(ctx contractapi.TransactionContextInterface, guid string, username string) ([]TaxonSuggestion, error) {
+	specimen, err := getSpecimen(ctx, guid)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = collectionQueryAccess(ctx, specimen.Collection, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return taxonSuggestionsByIndex(ctx, specimenTaxonSuggestionIndex, []string{guid})
+}
+
+// QueryPendingTaxonSuggestions returns the taxon suggestions of a collection which are still waiting to be approved or denied
+func (s *SmartContract) QueryPendingTaxonSuggestions(ctx contractapi.TransactionContextInterface, collection string, username string) ([]TaxonSuggestion, error) {
+	err := collectionQueryAccess(ctx, collection, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return taxonSuggestionsByIndex(ctx, pendingTaxonSuggestionIndex, []string{collection})
+}
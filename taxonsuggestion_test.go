@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestApprovedTaxonSuggestionBecomesCurrentDetermination(t *testing.T) {
+	h := newContractHarness(t)
+
+	h.fail("SuggestTaxon", "0", "student", "Pomacanthus imperator", "", "", "high", "banding")
+	h.fail("SuggestTaxon", "0", "assistant", "", "", "", "high", "banding")
+
+	suggestion := TaxonSuggestion{}
+	h.okInto(&suggestion, "SuggestTaxon", "0", "assistant", "Pomacanthus imperator", "", "", "high", "banding")
+
+	suggestions := []TaxonSuggestion{}
+	h.okInto(&suggestions, "QueryPendingTaxonSuggestions", "KU Ornithology", "manager")
+
+	if len(suggestions) != 1 || suggestions[0].ID != suggestion.ID {
+		t.Errorf("Pending suggestions are %+v", suggestions)
+	}
+
+	h.fail("ApproveTaxonSuggestion", suggestion.ID, "assistant", "")
+
+	specimen := Specimen{}
+	h.okInto(&specimen, "ApproveTaxonSuggestion", suggestion.ID, "manager", "agreed")
+
+	//The suggestion gave no determiner, so the specimen's own is kept
+	if specimen.Taxon != "Pomacanthus imperator" || specimen.Determiner != "Greenfield, David W" {
+		t.Errorf("Approved suggestion left specimen %+v", specimen)
+	}
+
+	history := DeterminationHistory{}
+	h.okInto(&history, "QueryDeterminations", "0", "public")
+
+	if history.Current == nil || history.Current.Taxon != "Pomacanthus imperator" || history.Current.Confidence != "high" || history.Current.Remarks != "banding" {
+		t.Errorf("Current determination is %+v", history.Current)
+	}
+
+	h.okInto(&suggestions, "QueryPendingTaxonSuggestions", "KU Ornithology", "manager")
+
+	if len(suggestions) != 0 {
+		t.Errorf("Approved suggestion is still pending")
+	}
+
+	h.fail("ApproveTaxonSuggestion", suggestion.ID, "manager", "again")
+}
+
+func TestDeniedAndWithdrawnTaxonSuggestions(t *testing.T) {
+	h := newContractHarness(t)
+
+	denied, withdrawn := TaxonSuggestion{}, TaxonSuggestion{}
+	h.okInto(&denied, "SuggestTaxon", "0", "assistant", "Pomacanthus imperator", "J Doe", "2020-01-01", "low", "")
+	h.okInto(&withdrawn, "SuggestTaxon", "0", "assistant", "Centropyge loricula", "J Doe", "2020-01-01", "low", "")
+
+	h.okInto(&denied, "DenyTaxonSuggestion", denied.ID, "manager", "banding does not match")
+	h.fail("WithdrawTaxonSuggestion", withdrawn.ID, "manager")
+	h.okInto(&withdrawn, "WithdrawTaxonSuggestion", withdrawn.ID, "assistant")
+
+	if denied.Status != pendingStatusDenied || withdrawn.Status != pendingStatusWithdrawn {
+		t.Errorf("Suggestions are %s and %s", denied.Status, withdrawn.Status)
+	}
+
+	suggestions := []TaxonSuggestion{}
+	h.okInto(&suggestions, "QueryTaxonSuggestions", "0", "public")
+
+	if len(suggestions) != 2 {
+		t.Errorf("Specimen has %d suggestions", len(suggestions))
+	}
+
+	specimen := Specimen{}
+	h.okInto(&specimen, "Query", "0", "public")
+
+	if specimen.Taxon != "Pygoplites diacanthus" {
+		t.Errorf("Specimen taxon changed to %s", specimen.Taxon)
+	}
+}
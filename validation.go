@@ -0,0 +1,327 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// isoDateLayouts are the ISO 8601 forms every collection accepts, from a full timestamp down to a year
+var isoDateLayouts = []string{time.RFC3339, isoDateLayout, "2006-01", "2006"}
+
+// dateFormatTokens translate the date formats collections are configured with (e.g. MM/DD/YYYY) into Go layouts.
+// Months and days are parsed with or without a leading zero, so MM/DD/YYYY accepts both 06/19/2003 and 6/19/2003.
+var dateFormatTokens = strings.NewReplacer("YYYY", "2006", "MM", "1", "DD", "2")
+
+var dateFormatSeparators = strings.NewReplacer("YYYY", "", "MM", "", "DD", "")
+
+// requirableFields are the specimen fields a collection may require
+var requirableFields = []string{"catalogNumber", "accessionNumber", "catalogDate", "cataloger", "taxon", "determiner", "determineDate", "fieldNumber", "fieldDate", "collector", "location", "latitude", "longitude", "habitat", "preparation", "image"}
+
+type FieldError struct {
+	Field   string `json:"field"`
+	Value   string `json:"value"`
+	Message string `json:"message"`
+}
+
+// ValidationError reports every field of a specimen which failed validation. Its message ends with the field errors as a JSON array.
+type ValidationError struct {
+	Guid   string       `json:"guid"`
+	Errors []FieldError `json:"errors"`
+}
+
+func (e *ValidationError) Error() string {
+	errorBytes, _ := json.Marshal(e.Errors)
+	return fmt.Sprintf("Specimen %s failed validation. %s", e.Guid, errorBytes)
+}
+
+// specimenFields returns the string fields of a specimen by their JSON names
+func specimenFields(specimen *Specimen) map[string]string {
+	fields := make(map[string]string)
+	value := reflect.ValueOf(specimen).Elem()
+
+	for i := 0; i < value.NumField(); i++ {
+		if value.Field(i).Kind() == reflect.String {
+			name := strings.Split(value.Type().Field(i).Tag.Get("json"), ",")[0]
+			fields[name] = value.Field(i).String()
+		}
+	}
+
+	return fields
+}
+
+// validDateFormat checks that a configured date format is made up of YYYY, MM and DD separated by punctuation
+func validDateFormat(format string) bool {
+	return strings.Contains(format, "YYYY") && strings.Trim(dateFormatSeparators.Replace(format), "-/. ") == ""
+}
+
+// dateLayouts returns the Go layouts of the ISO 8601 forms and the collection's configured date formats
+func dateLayouts(collect *Collection) []string {
+	layouts := append([]string{}, isoDateLayouts...)
+
+	for _, format := range collect.DateFormats {
+		layouts = append(layouts, dateFormatTokens.Replace(format))
+	}
+
+	return layouts
+}
+
+func checkDate(collect *Collection, date string) string {
+	for _, layout := range dateLayouts(collect) {
+		if _, err := time.Parse(layout, date); err == nil {
+			return ""
+		}
+	}
+
+	return fmt.Sprintf("is not an ISO 8601 date or a date in one of the formats %s", strings.Join(append([]string{"YYYY-MM-DD"}, collect.DateFormats...), ", "))
+}
+
+func checkCoordinate(coordinate string, limit float64) string {
+	value, err := strconv.ParseFloat(coordinate, 64)
+
+	if err != nil {
+		return "is not a decimal number"
+	}
+	if value < -limit || value > limit {
+		return fmt.Sprintf("is not between -%g and %g", limit, limit)
+	}
+
+	return ""
+}
+
+// validateSpecimen checks a specimen against the rules of its collection and reports every field which breaks them.
+// Only fields which differ from oldSpecimen are checked, so values recorded before validation do not block unrelated changes. oldSpecimen is nil for a new specimen.
+// conditionDate is the date of a condition entry being appended, or "" if there is none.
+func validateSpecimen(ctx contractapi.TransactionContextInterface, guid string, collect *Collection, oldSpecimen *Specimen, specimen *Specimen, conditionDate string) error {
+	fields := specimenFields(specimen)
+	oldFields := map[string]string{}
+
+	if oldSpecimen != nil {
+		oldFields = specimenFields(oldSpecimen)
+	}
+
+	changed := func(field string) bool {
+		return oldSpecimen == nil || fields[field] != oldFields[field]
+	}
+
+	errors := []FieldError{}
+	report := func(field string, value string, message string) {
+		if message != "" {
+			errors = append(errors, FieldError{field, value, message})
+		}
+	}
+
+	for _, field := range collect.RequiredFields {
+		if changed(field) && fields[field] == "" {
+			report(field, "", "is required")
+		}
+	}
+
+	if changed("latitude") || changed("longitude") {
+		if specimen.Latitude != "" {
+			report("latitude", specimen.Latitude, checkCoordinate(specimen.Latitude, 90))
+		}
+		if specimen.Longitude != "" {
+			report("longitude", specimen.Longitude, checkCoordinate(specimen.Longitude, 180))
+		}
+		if specimen.Latitude == "" && specimen.Longitude != "" {
+			report("latitude", "", "is required when longitude is given")
+		}
+		if specimen.Longitude == "" && specimen.Latitude != "" {
+			report("longitude", "", "is required when latitude is given")
+		}
+	}
+
+	for _, field := range []string{"catalogDate", "determineDate", "fieldDate"} {
+		if changed(field) && fields[field] != "" {
+			report(field, fields[field], checkDate(collect, fields[field]))
+		}
+	}
+
+	if conditionDate != "" {
+		report("conditionDate", conditionDate, checkDate(collect, conditionDate))
+	}
+
+	if changed("catalogNumber") && specimen.CatalogNumber != "" && collect.CatalogNumberPattern != "" {
+		pattern, err := regexp.Compile("^(?:" + collect.CatalogNumberPattern + ")$")
+
+		if err == nil && !pattern.MatchString(specimen.CatalogNumber) {
+			report("catalogNumber", specimen.CatalogNumber, fmt.Sprintf("does not match the pattern %s", collect.CatalogNumberPattern))
+		}
+	}
+
+	numbers := []struct {
+		field string
+		index string
+	}{{"catalogNumber", collectionCatalogNumberIndex}, {"accessionNumber", collectionAccessionNumberIndex}}
+
+	for _, number := range numbers {
+		if changed(number.field) && fields[number.field] != "" {
+			holders, err := numberHolders(ctx, number.index, specimen.Collection, fields[number.field], guid)
+
+			if err != nil {
+				return err
+			}
+
+			if len(holders) > 0 {
+				report(number.field, fields[number.field], fmt.Sprintf("is already used by specimen %s", strings.Join(holders, ", ")))
+			}
+		}
+	}
+
+	if changed("taxon") {
+		err := validateTaxon(ctx, specimen.Taxon)
+
+		if err != nil {
+			report("taxon", specimen.Taxon, "is not in the taxonomy registry")
+		}
+	}
+
+	if len(errors) > 0 {
+		return &ValidationError{guid, errors}
+	}
+
+	return nil
+}
+
+// SetCollectionValidation sets the rules specimens of a collection are validated against. Blank parameters clear their rule.
+func (s *SmartContract) SetCollectionValidation(ctx contractapi.TransactionContextInterface, name string, username string, catalogNumberPattern string, dateFormats string, requiredFields string) (*Collection, error) {
+	collect, err := getCollection(ctx, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := getUser(ctx, username)
+
+	if err != nil {
+		return nil, err
+	}
+
+	username = user.Username
+
+	if role, ok := user.Membership[name]; ok {
+		if role != "M" {
+			return nil, fmt.Errorf("%s is not the Manager for collection %s", username, name)
+		}
+	} else {
+		return nil, fmt.Errorf("%s is not registered with collection %s", username, name)
+	}
+
+	_, err = regexp.Compile(catalogNumberPattern)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s is not a valid catalog number pattern. %s", catalogNumberPattern, err.Error())
+	}
+
+	formats := []string{}
+
+	for _, format := range strings.Split(dateFormats, ",") {
+		format = strings.TrimSpace(format)
+
+		if format == "" {
+			continue
+		}
+		if !validDateFormat(format) {
+			return nil, fmt.Errorf("%s is not a valid date format. Date formats are made up of YYYY, MM and DD (e.g. MM/DD/YYYY)", format)
+		}
+
+		formats = append(formats, format)
+	}
+
+	required := []string{}
+
+	for _, field := range strings.Split(requiredFields, ",") {
+		field = strings.TrimSpace(field)
+
+		if field == "" {
+			continue
+		}
+
+		requirable := false
+		for _, requirableField := range requirableFields {
+			requirable = requirable || field == requirableField
+		}
+		if !requirable {
+			return nil, fmt.Errorf("%s is not a field which may be required. Fields which may be required are %s", field, strings.Join(requirableFields, ", "))
+		}
+
+		required = append(required, field)
+	}
+
+	oldCollection := *collect
+	collect.CatalogNumberPattern = catalogNumberPattern
+	collect.DateFormats = formats
+	collect.RequiredFields = required
+
+	attributionString := fmt.Sprintf("Updated Collection %s validation rules", name)
+	err = attribute(ctx, username, "SetCollectionValidation", collectionObjectType, name, "", attributionString)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to put to world state. %s", err.Error())
+	}
+
+	collectionBytes, _ := json.Marshal(collect)
+	err = putState(ctx, collectionObjectType, name, collectionBytes)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = emitEvent(ctx, ChangeEvent{Action: "SetCollectionValidation", ObjectType: collectionObjectType, ID: name, Actor: username, ChangedFields: changedFields(&oldCollection, collect)})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return collect, nil
+}
+
+// ValidateSpecimen checks a SpecimenPatch as CreateSpecimen or PatchSpecimen would validate it, without writing anything, and returns the field errors
+func (s *SmartContract) ValidateSpecimen(ctx contractapi.TransactionContextInterface, specimenPatch string) ([]FieldError, error) {
+	patch, err := parsePatch(specimenPatch)
+
+	if err != nil {
+		return nil, err
+	}
+
+	specimenBytes, err := getState(ctx, specimenObjectType, patch.Guid)
+
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from world state. %s", err.Error())
+	}
+
+	var oldSpecimen *Specimen
+	specimen := Specimen{}
+
+	if specimenBytes != nil {
+		oldSpecimen = new(Specimen)
+		_ = json.Unmarshal(specimenBytes, oldSpecimen)
+		specimen = *oldSpecimen
+	}
+
+	patch.applyTo(&specimen)
+
+	collect, err := getCollection(ctx, specimen.Collection)
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateSpecimen(ctx, patch.Guid, collect, oldSpecimen, &specimen, patch.conditionDate())
+
+	if validationError, ok := err.(*ValidationError); ok {
+		return validationError.Errors, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return []FieldError{}, nil
+}
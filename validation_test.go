@@ -0,0 +1,90 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// expectFieldErrors checks that a ValidationError message reports each of the fields
+func expectFieldErrors(t *testing.T, message string, fields ...string) {
+	t.Helper()
+
+	for _, field := range fields {
+		if !strings.Contains(message, `"field":"`+field+`"`) {
+			t.Errorf("%s does not report %s", message, field)
+		}
+	}
+}
+
+func TestSpecimensAreValidated(t *testing.T) {
+	h := newContractHarness(t)
+
+	message := h.fail("CreateSpecimen", `{"guid":"v1","updater":"manager","collection":"KU Ornithology","latitude":"abc","longitude":"200","fieldDate":"2020/13/01","catalogDate":"06/19/2003"}`)
+	expectFieldErrors(t, message, "latitude", "longitude", "fieldDate")
+
+	if strings.Contains(message, `"field":"catalogDate"`) {
+		t.Errorf("%s reports a date in the collection's format", message)
+	}
+
+	h.ok("CreateSpecimen", `{"guid":"v1","updater":"manager","collection":"KU Ornithology","latitude":"10","longitude":"20","fieldDate":"2020-03","catalogDate":"06/19/2003"}`)
+
+	fieldErrors := []FieldError{}
+	h.okInto(&fieldErrors, "ValidateSpecimen", `{"guid":"v2","updater":"manager","collection":"KU Ornithology","latitude":"91","longitude":"20"}`)
+
+	if len(fieldErrors) != 1 || fieldErrors[0].Field != "latitude" {
+		t.Errorf("Validated with errors %+v", fieldErrors)
+	}
+
+	h.fail("Query", "v2", "manager")
+
+	//Months and days may be written without their leading zero, but not as numbers out of range
+	h.okInto(&fieldErrors, "ValidateSpecimen", `{"guid":"v3","updater":"manager","collection":"KU Ornithology","catalogDate":"6/9/2003","fieldDate":"13/19/2003"}`)
+
+	if len(fieldErrors) != 1 || fieldErrors[0].Field != "fieldDate" {
+		t.Errorf("Validated with errors %+v", fieldErrors)
+	}
+}
+
+func TestSetCollectionValidation(t *testing.T) {
+	h := newContractHarness(t)
+	h.ok("CreateSpecimen", `{"guid":"v1","updater":"manager","collection":"KU Ornithology"}`)
+
+	h.fail("SetCollectionValidation", "KU Ornithology", "curator", "", "", "")
+	h.fail("SetCollectionValidation", "KU Ornithology", "manager", "", "Q/YYYY", "")
+	h.fail("SetCollectionValidation", "KU Ornithology", "manager", "", "", "loans")
+
+	collect := Collection{}
+	h.okInto(&collect, "SetCollectionValidation", "KU Ornithology", "manager", "[0-9]+", "DD.MM.YYYY", "taxon,catalogNumber")
+
+	if collect.CatalogNumberPattern != "[0-9]+" || len(collect.DateFormats) != 1 || collect.DateFormats[0] != "DD.MM.YYYY" {
+		t.Errorf("Collection rules are %+v", collect)
+	}
+
+	message := h.fail("PatchSpecimen", `{"guid":"v1","updater":"manager","catalogNumber":"A1","fieldDate":"06/19/2003"}`)
+	expectFieldErrors(t, message, "catalogNumber", "fieldDate")
+
+	h.ok("PatchSpecimen", `{"guid":"v1","updater":"manager","catalogNumber":"12","fieldDate":"19.06.2003"}`)
+
+	message = h.fail("CreateSpecimen", `{"guid":"v2","updater":"manager","collection":"KU Ornithology","catalogNumber":"13"}`)
+	expectFieldErrors(t, message, "taxon")
+}
+
+func TestExportedDatesFollowCollectionFormats(t *testing.T) {
+	h := newContractHarness(t)
+	h.ok("SetCollectionValidation", "KU Ornithology", "manager", "", "DD.MM.YYYY,MM/YYYY", "")
+	h.ok("CreateSpecimen", `{"guid":"a","updater":"manager","collection":"KU Ornithology","fieldDate":"19.06.2003","determineDate":"06/2004"}`)
+	h.ok("CreateSpecimen", `{"guid":"b","updater":"manager","collection":"KU Ornithology","fieldDate":"2003-06","determineDate":"2004-06-19"}`)
+	h.ok("CreateSpecimen", `{"guid":"c","updater":"manager","collection":"KU Ornithology","fieldDate":"9.6.2003","determineDate":"6/2004"}`)
+
+	page := OccurrencePage{}
+	h.okInto(&page, "ExportDarwinCore", "KU Ornithology", "public", "10", "")
+
+	dates := make(map[string][2]string)
+	for _, record := range page.Records {
+		dates[record.OccurrenceID] = [2]string{record.EventDate, record.DateIdentified}
+	}
+
+	if dates["a"] != [2]string{"2003-06-19", "2004-06"} || dates["b"] != [2]string{"2003-06", "2004-06-19"} || dates["c"] != [2]string{"2003-06-09", "2004-06"} {
+		t.Errorf("Exported dates %v", dates)
+	}
+}